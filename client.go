@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -60,6 +61,23 @@ func (c *Client) WithFlushInterval(dur time.Duration) (*Client, func()) {
 	return c, c.flush
 }
 
+// WithHeartbeat logs a heartbeat for the instance of the app at every
+// interval, so the server can report periods in which logs from it were lost.
+// See GET /gaps. Each replica of an app should use a distinct instance; if
+// instance is empty, the hostname is used.
+func (c *Client) WithHeartbeat(app, instance string, dur time.Duration) *Client {
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	go func() {
+		c.Log(NewHeartbeat(app, instance, time.Now(), dur))
+		for t := range time.Tick(dur) {
+			c.Log(NewHeartbeat(app, instance, t, dur))
+		}
+	}()
+	return c
+}
+
 // marshalBuffer to JSON. If the buffer is empty, marshalBuffer reports nil.
 // This is not thread-safe, so protect any call with a mutex.
 func (c *Client) marshalBuffer() ([]byte, error) {
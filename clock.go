@@ -0,0 +1,20 @@
+package sls
+
+import "time"
+
+// Clock tells the time. It's used when rotating and deleting logfiles, so
+// alternative implementations can control those deterministically.
+type Clock interface {
+	Now() time.Time
+	Tick(time.Duration) <-chan time.Time
+}
+
+// RealClock satisfies the Clock interface using the system time.
+type RealClock struct{}
+
+// Now reports the current local time.
+func (RealClock) Now() time.Time { return time.Now() }
+
+// Tick delivers the current time on the channel at every interval. Like
+// time.Tick, the underlying ticker is never stopped.
+func (RealClock) Tick(dur time.Duration) <-chan time.Time { return time.Tick(dur) }
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/pkg/errors"
+)
+
+// benchConfig describes the synthetic load generated by sls bench.
+type benchConfig struct {
+	url      string
+	apiKey   string
+	rate     int
+	size     int
+	clients  int
+	batch    int
+	duration time.Duration
+}
+
+// benchErrorBackoff is how long a client waits after a failed request, so a
+// server that's down isn't hammered in a hot loop.
+const benchErrorBackoff = 100 * time.Millisecond
+
+// benchResult is collected by each client and merged for the report.
+// Latencies are only recorded for successful requests, so fast failures such
+// as refused connections don't skew the percentiles.
+type benchResult struct {
+	requests  int
+	lines     int
+	latencies []time.Duration
+	errs      map[string]int
+}
+
+// bench generates load against a running server and prints the achieved
+// throughput, latency percentiles, and errors. It exits with 1 if the
+// arguments are invalid.
+func bench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	conf := benchConfig{}
+	flags.StringVar(&conf.url, "url", "http://localhost:3000", "server to send logs to")
+	flags.StringVar(&conf.apiKey, "api-key", os.Getenv("SLS_API_KEY"),
+		"API key (env SLS_API_KEY)")
+	flags.IntVar(&conf.rate, "rate", 0, "target lines per second across all clients; 0 is unlimited")
+	flags.IntVar(&conf.size, "size", 128, "bytes per line")
+	flags.IntVar(&conf.clients, "clients", 4, "number of concurrent clients")
+	flags.IntVar(&conf.batch, "batch", 10, "lines per request")
+	flags.DurationVar(&conf.duration, "duration", 10*time.Second, "how long to generate load")
+	flags.Parse(args)
+	if conf.size <= 0 || conf.clients <= 0 || conf.batch <= 0 ||
+		conf.rate < 0 || conf.duration <= 0 {
+		fmt.Fprintln(os.Stderr, "size, clients, batch, and duration must be positive")
+		os.Exit(1)
+	}
+
+	body, err := benchBody(conf.size, conf.batch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	client := cleanhttp.DefaultPooledClient()
+	client.Timeout = 10 * time.Second
+
+	results := make([]*benchResult, conf.clients)
+	deadline := time.Now().Add(conf.duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = benchClient(client, conf, body, deadline)
+		}(i)
+	}
+	wg.Wait()
+	printBenchReport(os.Stdout, conf, results, time.Since(start))
+}
+
+// benchBody builds the JSON request body of batch lines, each the given size.
+func benchBody(size, batch int) ([]byte, error) {
+	line := strings.Repeat("x", size)
+	lines := make([]string, batch)
+	for i := range lines {
+		lines[i] = line
+	}
+	byt, err := json.Marshal(lines)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal")
+	}
+	return byt, nil
+}
+
+// benchClient posts batches until the deadline, pacing requests to its share
+// of the target rate.
+func benchClient(
+	client *http.Client,
+	conf benchConfig,
+	body []byte,
+	deadline time.Time,
+) *benchResult {
+	res := &benchResult{errs: map[string]int{}}
+	var tick <-chan time.Time
+	if conf.rate > 0 {
+		perClient := float64(conf.rate) / float64(conf.clients*conf.batch)
+
+		// Very high rates truncate to 0, which NewTicker rejects, so
+		// send as fast as possible instead
+		interval := time.Duration(float64(time.Second) / perClient)
+		if interval < 1 {
+			interval = 1
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	done := time.NewTimer(time.Until(deadline))
+	defer done.Stop()
+	for time.Now().Before(deadline) {
+		reqStart := time.Now()
+		err := benchPost(client, conf, body)
+		res.requests++
+		wait := tick
+		if err != nil {
+			res.errs[err.Error()]++
+			wait = time.After(benchErrorBackoff)
+		} else {
+			res.latencies = append(res.latencies, time.Since(reqStart))
+			res.lines += conf.batch
+		}
+		if wait == nil {
+			continue
+		}
+
+		// Wait for the next tick or backoff, but don't outlast the
+		// deadline
+		select {
+		case <-wait:
+		case <-done.C:
+			return res
+		}
+	}
+	return res
+}
+
+func benchPost(client *http.Client, conf benchConfig, body []byte) error {
+	req, err := http.NewRequest("POST", conf.url+"/log", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "new request")
+	}
+	req.Header.Set("X-API-Key", conf.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "do")
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection is reused
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func printBenchReport(
+	w io.Writer,
+	conf benchConfig,
+	results []*benchResult,
+	elapsed time.Duration,
+) {
+	requests, lines := 0, 0
+	latencies := []time.Duration{}
+	errs := map[string]int{}
+	for _, res := range results {
+		requests += res.requests
+		lines += res.lines
+		latencies = append(latencies, res.latencies...)
+		for msg, n := range res.errs {
+			errs[msg] += n
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	errCount := 0
+	for _, n := range errs {
+		errCount += n
+	}
+	secs := elapsed.Seconds()
+	fmt.Fprintf(w, "duration:   %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "clients:    %d\n", conf.clients)
+	fmt.Fprintf(w, "requests:   %d (%.1f/s)\n", requests, float64(requests)/secs)
+	fmt.Fprintf(w, "lines:      %d (%.1f/s)\n", lines, float64(lines)/secs)
+	fmt.Fprintf(w, "throughput: %.2f MB/s\n", float64(lines*conf.size)/secs/(1<<20))
+	if requests > 0 {
+		fmt.Fprintf(w, "errors:     %d (%.2f%%)\n", errCount,
+			100*float64(errCount)/float64(requests))
+	}
+	if len(latencies) > 0 {
+		fmt.Fprintf(w, "latency:    p50 %s  p90 %s  p99 %s  max %s (successes only)\n",
+			percentile(latencies, 50), percentile(latencies, 90),
+			percentile(latencies, 99),
+			latencies[len(latencies)-1].Round(time.Microsecond))
+	}
+	for msg, n := range errs {
+		fmt.Fprintf(w, "  %d: %s\n", n, msg)
+	}
+}
+
+// percentile reports the pth percentile of sorted durations using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1].Round(time.Microsecond)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBenchClientRate(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+		}))
+	defer ts.Close()
+	body, err := benchBody(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := map[string]struct {
+		rate int
+		min  int64
+		max  int64
+	}{
+		// One request per second sends the first immediately and
+		// stops at the deadline rather than waiting for the next tick
+		"slow":      {rate: 1, min: 1, max: 1},
+		"too fast":  {rate: 2e9, min: 1, max: 1 << 40},
+		"unlimited": {rate: 0, min: 1, max: 1 << 40},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			requests.Store(0)
+			conf := benchConfig{
+				url:     ts.URL,
+				rate:    tc.rate,
+				clients: 1,
+				batch:   1,
+			}
+			start := time.Now()
+			res := benchClient(ts.Client(), conf, body,
+				start.Add(100*time.Millisecond))
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatalf("expected to stop at deadline, took %s", elapsed)
+			}
+			n := requests.Load()
+			if n < tc.min || n > tc.max {
+				t.Fatalf("expected %d-%d requests, got %d", tc.min, tc.max, n)
+			}
+			if len(res.errs) > 0 {
+				t.Fatalf("unexpected errors %v", res.errs)
+			}
+			if int64(res.lines) != n {
+				t.Fatalf("expected %d lines, got %d", n, res.lines)
+			}
+		})
+	}
+}
+
+func TestBenchClientErrors(t *testing.T) {
+	// Requests to a closed server fail immediately, which would spin
+	// without the backoff
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+	body, err := benchBody(8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := benchConfig{url: url, clients: 1, batch: 1, size: 8}
+	res := benchClient(http.DefaultClient, conf, body,
+		time.Now().Add(2*benchErrorBackoff+benchErrorBackoff/2))
+	if res.requests < 1 || res.requests > 3 {
+		t.Fatalf("expected 1-3 requests with backoff, got %d", res.requests)
+	}
+	if len(res.latencies) != 0 || res.lines != 0 {
+		t.Fatalf("expected no successes, got %+v", res)
+	}
+	n := 0
+	for _, c := range res.errs {
+		n += c
+	}
+	if n != res.requests {
+		t.Fatalf("expected %d errors, got %d", res.requests, n)
+	}
+
+	// Latencies reflect only successful requests, so none are reported
+	var buf bytes.Buffer
+	printBenchReport(&buf, conf, []*benchResult{res}, time.Second)
+	if strings.Contains(buf.String(), "latency") {
+		t.Fatalf("expected no latency without successes:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "(100.00%)") {
+		t.Fatalf("expected every request to fail:\n%s", buf.String())
+	}
+}
@@ -2,29 +2,313 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/egtann/sls"
 	"github.com/pkg/errors"
 )
 
 type config struct {
 	RetainFor time.Duration
-	Dir       string
+	Dirs      []string
 	Port      string
 	APIKey    string
+
+	// AdminPort serves unauthenticated pprof and debug endpoints. If
+	// empty, they're disabled. AdminAddr is the host on which they
+	// listen, which defaults to localhost to keep them private.
+	AdminPort string
+	AdminAddr string
+
+	// MinFreeBytes on the log directory's disk for the health check to
+	// pass. If zero, the disk check is skipped.
+	MinFreeBytes uint64
+
+	// AllowedNets may post logs. If empty, any IP may post logs.
+	AllowedNets []*net.IPNet
+
+	// TLSCert and TLSKey are paths to PEM files. If set, sls serves
+	// HTTPS.
+	TLSCert string
+	TLSKey  string
+
+	// Format of entries in logfiles.
+	Format sls.Format
+
+	// ClientCA is a path to a PEM file of CAs. If set, clients posting
+	// logs must present a certificate signed by one of them.
+	ClientCA string
+}
+
+// option is a single config setting. Each can be set in the config file as
+// KEY=value, in the environment as SLS_KEY, or with the flag -key. Flags take
+// precedence over the environment, which takes precedence over the file.
+type option struct {
+	key   string
+	usage string
+	def   string
+
+	// multi options may be given more than once, e.g. DIR. In the
+	// environment, multiple values are separated by commas.
+	multi bool
+
+	// required options must be set in at least one layer.
+	required bool
+
+	set func(c *config, val string) error
+	get func(c *config) []string
+}
+
+var options = []option{
+	{
+		key: "DIR",
+		usage: "directory in which to store logs; repeat to shard across " +
+			"directories, which loses the order of batches between them",
+		multi:    true,
+		required: true,
+		set: func(c *config, val string) error {
+			c.Dirs = append(c.Dirs, val)
+			return nil
+		},
+		get: func(c *config) []string { return c.Dirs },
+	},
+	{
+		key:   "PORT",
+		usage: "port on which to receive logs",
+		def:   "3000",
+		set: func(c *config, val string) error {
+			if _, err := strconv.Atoi(val); err != nil {
+				return fmt.Errorf("%s PORT must be int", val)
+			}
+			c.Port = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{c.Port} },
+	},
+	{
+		key:   "RETAIN_FOR_DAYS",
+		usage: "days to keep logs before deleting them",
+		def:   "30",
+		set: func(c *config, val string) error {
+			i, err := strconv.Atoi(val)
+			if err != nil || i <= 0 {
+				return fmt.Errorf("%s RETAIN_FOR_DAYS must be positive int", val)
+			}
+			c.RetainFor = time.Duration(i) * 24 * time.Hour
+			return nil
+		},
+		get: func(c *config) []string {
+			days := int(c.RetainFor / (24 * time.Hour))
+			return []string{strconv.Itoa(days)}
+		},
+	},
+	{
+		key:      "API_KEY",
+		usage:    "key clients must send in the X-API-Key header",
+		required: true,
+		set: func(c *config, val string) error {
+			c.APIKey = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{"<redacted>"} },
+	},
+	{
+		key:   "ADMIN_PORT",
+		usage: "port for unauthenticated pprof and debug endpoints; disabled if empty",
+		set: func(c *config, val string) error {
+			if _, err := strconv.Atoi(val); err != nil {
+				return fmt.Errorf("%s ADMIN_PORT must be int", val)
+			}
+			c.AdminPort = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{c.AdminPort} },
+	},
+	{
+		key:   "ADMIN_ADDR",
+		usage: "host on which to serve ADMIN_PORT; use 0.0.0.0 to expose it on every interface",
+		def:   "127.0.0.1",
+		set: func(c *config, val string) error {
+			c.AdminAddr = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{c.AdminAddr} },
+	},
+	{
+		key:   "MIN_FREE_MB",
+		usage: "free disk space required to pass health checks; disabled if 0",
+		def:   "0",
+		set: func(c *config, val string) error {
+			i, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s MIN_FREE_MB must be int", val)
+			}
+			c.MinFreeBytes = i << 20
+			return nil
+		},
+		get: func(c *config) []string {
+			return []string{strconv.FormatUint(c.MinFreeBytes>>20, 10)}
+		},
+	},
+	{
+		key:   "FORMAT",
+		usage: "framing of entries in logfiles: lines, escaped, or length",
+		def:   string(sls.FormatLines),
+		set: func(c *config, val string) error {
+			f, err := sls.ParseFormat(val)
+			if err != nil {
+				return fmt.Errorf("%s FORMAT must be lines, escaped, or length", val)
+			}
+			c.Format = f
+			return nil
+		},
+		get: func(c *config) []string { return []string{string(c.Format)} },
+	},
+	{
+		key: "ALLOW_CIDR",
+		usage: "network allowed to post logs, e.g. 10.0.0.0/8; repeat to allow " +
+			"several; reading logs needs only API_KEY",
+		multi: true,
+		set: func(c *config, val string) error {
+			_, n, err := net.ParseCIDR(val)
+			if err != nil {
+				return fmt.Errorf("%s ALLOW_CIDR must be CIDR", val)
+			}
+			c.AllowedNets = append(c.AllowedNets, n)
+			return nil
+		},
+		get: func(c *config) []string {
+			vals := []string{}
+			for _, n := range c.AllowedNets {
+				vals = append(vals, n.String())
+			}
+			return vals
+		},
+	},
+	{
+		key:   "TLS_CERT",
+		usage: "PEM certificate for serving HTTPS",
+		set: func(c *config, val string) error {
+			c.TLSCert = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{c.TLSCert} },
+	},
+	{
+		key:   "TLS_KEY",
+		usage: "PEM private key for serving HTTPS",
+		set: func(c *config, val string) error {
+			c.TLSKey = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{c.TLSKey} },
+	},
+	{
+		key: "CLIENT_CA",
+		usage: "PEM CAs which must sign client certificates to post logs; " +
+			"reading logs needs only API_KEY; requires TLS",
+		set: func(c *config, val string) error {
+			c.ClientCA = val
+			return nil
+		},
+		get: func(c *config) []string { return []string{c.ClientCA} },
+	},
+}
+
+// flagName converts a config key like RETAIN_FOR_DAYS to retain-for-days.
+func (o option) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(o.key), "_", "-")
+}
+
+func (o option) envName() string { return "SLS_" + o.key }
+
+// layer holds the raw values for each key from a single source.
+type layer map[string][]string
+
+// multiFlag collects every value given for a flag.
+type multiFlag []string
+
+func (m *multiFlag) String() string { return strings.Join(*m, ",") }
+
+func (m *multiFlag) Set(val string) error {
+	if val != "" {
+		*m = append(*m, val)
+	}
+	return nil
+}
+
+// loadConfig merges the defaults, config file, environment, and flags in
+// args, in increasing order of precedence. A higher layer replaces every
+// value for a key, so -dir on the command line replaces all DIR entries in
+// the file. The config file is optional unless -c is given explicitly.
+func loadConfig(name string, args []string) (*config, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	pth := flags.String("c", "sls.conf", "config filepath")
+	flagVals := map[string]*multiFlag{}
+	for _, o := range options {
+		v := &multiFlag{}
+		flagVals[o.key] = v
+		usage := fmt.Sprintf("%s (env %s)", o.usage, o.envName())
+		flags.Var(v, o.flagName(), usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument: %s", flags.Arg(0))
+	}
+	explicitPath := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "c" {
+			explicitPath = true
+		}
+	})
+
+	vals := layer{}
+	for _, o := range options {
+		if o.def != "" {
+			vals[o.key] = []string{o.def}
+		}
+	}
+	fileVals, err := loadConfigFile(*pth)
+	switch {
+	case os.IsNotExist(errors.Cause(err)) && !explicitPath:
+		// No config file is fine when configuring through the
+		// environment or flags
+	case err != nil:
+		return nil, errors.Wrap(err, "load config file")
+	}
+	vals.merge(fileVals)
+	vals.merge(loadConfigEnv())
+	for key, v := range flagVals {
+		if len(*v) > 0 {
+			vals[key] = *v
+		}
+	}
+	return newConfig(vals)
+}
+
+// merge replaces the values in l with any set in other.
+func (l layer) merge(other layer) {
+	for key, v := range other {
+		l[key] = v
+	}
 }
 
-func loadConfig(pth string) (*config, error) {
+func loadConfigFile(pth string) (layer, error) {
 	fi, err := os.Open(pth)
 	if err != nil {
 		return nil, errors.Wrap(err, "open")
 	}
 	defer fi.Close()
-	c := config{}
+	vals := layer{}
 	scn := bufio.NewScanner(fi)
 	for scn.Scan() {
 		line := scn.Text()
@@ -45,44 +329,97 @@ func loadConfig(pth string) (*config, error) {
 				val = val[1 : len(val)-1]
 			}
 		}
-		switch key {
-		case "DIR":
-			c.Dir = val
-		case "PORT":
-			if _, err = strconv.Atoi(val); err != nil {
-				return nil, fmt.Errorf("%s PORT must be int", val)
-			}
-			c.Port = val
-		case "RETAIN_FOR_DAYS":
-			i, err := strconv.Atoi(val)
-			if err != nil {
-				return nil, fmt.Errorf("%s RETAIN_FOR_DAYS must be int", val)
-			}
-			c.RetainFor = time.Duration(i) * 24 * time.Hour
-		case "API_KEY":
-			c.APIKey = val
-		default:
+		o, ok := lookupOption(key)
+		if !ok {
 			return nil, fmt.Errorf("unknown config key: %s", key)
 		}
+		if val == "" {
+			// Empty values leave the default in place rather than
+			// overriding it with an invalid zero value
+			continue
+		}
+		if !o.multi {
+			vals[key] = nil
+		}
+		vals[key] = append(vals[key], val)
 	}
 	if err = scn.Err(); err != nil {
 		return nil, errors.Wrap(err, "scan")
 	}
-	errMsg := ""
-	if c.APIKey == "" {
-		errMsg += "missing API_KEY\n"
+	return vals, nil
+}
+
+func loadConfigEnv() layer {
+	vals := layer{}
+	for _, o := range options {
+		val := os.Getenv(o.envName())
+		if val == "" {
+			continue
+		}
+		if !o.multi {
+			vals[o.key] = []string{val}
+			continue
+		}
+		for _, v := range strings.Split(val, ",") {
+			if v != "" {
+				vals[o.key] = append(vals[o.key], v)
+			}
+		}
 	}
-	if c.RetainFor == time.Duration(0) {
-		errMsg += "missing RETAIN_FOR_DAYS\n"
+	return vals
+}
+
+func lookupOption(key string) (option, bool) {
+	for _, o := range options {
+		if o.key == key {
+			return o, true
+		}
+	}
+	return option{}, false
+}
+
+// newConfig validates the merged values and reports every problem at once.
+func newConfig(vals layer) (*config, error) {
+	c := config{}
+	errMsg := ""
+	for _, o := range options {
+		vs := vals[o.key]
+		if len(vs) == 0 {
+			if o.required {
+				errMsg += fmt.Sprintf("missing %s\n", o.key)
+			}
+			continue
+		}
+		for _, v := range vs {
+			if err := o.set(&c, v); err != nil {
+				errMsg += err.Error() + "\n"
+			}
+		}
 	}
-	if c.Port == "" {
-		errMsg += "missing PORT\n"
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errMsg += "TLS_CERT and TLS_KEY must be set together\n"
 	}
-	if c.Dir == "" {
-		errMsg += "missing DIR\n"
+	if c.ClientCA != "" && c.TLSCert == "" {
+		errMsg += "CLIENT_CA requires TLS_CERT and TLS_KEY\n"
 	}
 	if errMsg != "" {
 		return nil, errors.New(errMsg)
 	}
 	return &c, nil
 }
+
+// write the effective config in the config file format. Secrets are
+// redacted.
+func (c *config) write(w io.Writer) error {
+	for _, o := range options {
+		for _, v := range o.get(c) {
+			if v == "" {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s=%s\n", o.key, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
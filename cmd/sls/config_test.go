@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tcs := map[string]struct {
+		file    string
+		env     map[string]string
+		args    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		"default": {
+			file: "DIR=/tmp\nAPI_KEY=k\n",
+			want: 30 * 24 * time.Hour,
+		},
+		"file": {
+			file: "DIR=/tmp\nAPI_KEY=k\nRETAIN_FOR_DAYS=3\n",
+			want: 3 * 24 * time.Hour,
+		},
+		"empty in file keeps default": {
+			file: "DIR=/tmp\nAPI_KEY=k\nRETAIN_FOR_DAYS=\n",
+			want: 30 * 24 * time.Hour,
+		},
+		"empty in env keeps file": {
+			file: "DIR=/tmp\nAPI_KEY=k\nRETAIN_FOR_DAYS=3\n",
+			env:  map[string]string{"SLS_RETAIN_FOR_DAYS": ""},
+			want: 3 * 24 * time.Hour,
+		},
+		"empty flag keeps env": {
+			file: "DIR=/tmp\nAPI_KEY=k\n",
+			env:  map[string]string{"SLS_RETAIN_FOR_DAYS": "5"},
+			args: []string{"-retain-for-days="},
+			want: 5 * 24 * time.Hour,
+		},
+		"flag overrides env": {
+			file: "DIR=/tmp\nAPI_KEY=k\n",
+			env:  map[string]string{"SLS_RETAIN_FOR_DAYS": "5"},
+			args: []string{"-retain-for-days", "7"},
+			want: 7 * 24 * time.Hour,
+		},
+		"zero": {
+			file:    "DIR=/tmp\nAPI_KEY=k\nRETAIN_FOR_DAYS=0\n",
+			wantErr: true,
+		},
+		"empty required": {
+			file:    "DIR=\nAPI_KEY=k\n",
+			wantErr: true,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			for _, o := range options {
+				t.Setenv(o.envName(), "")
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			pth := filepath.Join(t.TempDir(), "sls.conf")
+			err := ioutil.WriteFile(pth, []byte(tc.file), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := append([]string{"-c", pth}, tc.args...)
+			conf, err := loadConfig("test", args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", conf)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.RetainFor != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, conf.RetainFor)
+			}
+		})
+	}
+}
@@ -2,25 +2,43 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/egtann/sls"
 	slsHTTP "github.com/egtann/sls/http"
 	"github.com/egtann/up"
+	"github.com/pkg/errors"
 )
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
-	confFilePath := flag.String("c", "sls.conf", "config filepath")
-	flag.Parse()
 	log := &logger{}
-	conf, err := loadConfig(*confFilePath)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "checkconfig":
+			checkConfig(os.Args[2:])
+			return
+		case "bench":
+			bench(os.Args[2:])
+			return
+		}
+	}
+	conf, err := loadConfig(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -30,15 +48,25 @@ func main() {
 	}
 
 	// TODO - load an error reporter and pass into ServeNewMux
-	service, err := slsHTTP.NewService(log, conf.Dir, conf.APIKey, version)
+	shards := []sls.FS{}
+	for _, dir := range conf.Dirs {
+		shards = append(shards, sls.DirFS(dir))
+	}
+	service, err := slsHTTP.NewService(log, shards, sls.RealClock{},
+		conf.APIKey, version)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer service.Shutdown()
+	service.WithMinFreeBytes(conf.MinFreeBytes)
+	service.WithAllowedNets(conf.AllowedNets)
+	service.WithFormat(conf.Format)
 
 	// Periodically check if the file needs to be split and delete old
 	// files outside the retention period
-	go service.EnforceRetentionPolicy(conf.RetainFor)
+	if err = service.EnforceRetentionPolicy(conf.RetainFor); err != nil {
+		log.Fatal(err)
+	}
 
 	srv := &http.Server{
 		Addr:           ":" + conf.Port,
@@ -47,15 +75,79 @@ func main() {
 		WriteTimeout:   0,
 		MaxHeaderBytes: 1 << 20,
 	}
+	srv.RegisterOnShutdown(service.StopTails)
+	if conf.ClientCA != "" {
+		srv.TLSConfig, err = clientCATLSConfig(conf.ClientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		service.WithClientCerts()
+	}
 	go func() {
-		if err = srv.ListenAndServe(); err != nil {
+		var err error
+		if conf.TLSCert != "" {
+			err = srv.ListenAndServeTLS(conf.TLSCert, conf.TLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	}()
 	log.Printf("listening on %s\n", conf.Port)
+	if conf.AdminPort != "" {
+		adminSrv := &http.Server{
+			Addr:           net.JoinHostPort(conf.AdminAddr, conf.AdminPort),
+			Handler:        service.AdminMux(),
+			MaxHeaderBytes: 1 << 20,
+		}
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != nil {
+				log.Fatal(err)
+			}
+		}()
+		log.Printf("admin listening on %s\n", adminSrv.Addr)
+	}
 	gracefulRestart(srv, time.Second)
 }
 
+// clientCATLSConfig verifies client certificates against the CAs in the PEM
+// file. Certificates are verified only if given, so that health checks work
+// without one. The service enforces them when posting logs.
+func clientCATLSConfig(pth string) (*tls.Config, error) {
+	byt, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return nil, errors.Wrap(err, "read client ca")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(byt) {
+		return nil, fmt.Errorf("no certificates in %s", pth)
+	}
+	cfg := &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		MinVersion: tls.VersionTLS12,
+	}
+	return cfg, nil
+}
+
+// checkConfig validates the merged config and prints the effective settings.
+// It exits with 1 if the config is invalid.
+func checkConfig(args []string) {
+	conf, err := loadConfig("checkconfig", args)
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%s\n", err)
+		os.Exit(1)
+	}
+	if err = conf.write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 // gracefulRestart listens for an interrupt or terminate signal. When either is
 // received, it stops accepting new connections and allows all existing
 // connections up to the timeout duration to complete. If connections do not
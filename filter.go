@@ -0,0 +1,275 @@
+package sls
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Filter matches log lines against a set of terms, all of which must match.
+// Terms are separated by spaces and take the form field OP value, such as
+// `app=api level>=warn msg~timeout`. Values containing spaces may be
+// double-quoted.
+//
+// Supported operators are =, !=, ~ and !~ (regular expressions), and >, >=,
+// <, and <=. Comparisons use level order (debug < info < warn < error <
+// fatal) when both sides are levels, numeric order when both are numbers, and
+// string order otherwise. A term with no field, such as `~timeout`, matches
+// against the entire line.
+//
+// Fields come from the top-level keys of a JSON object, or else from
+// key=value pairs in the line. If the line has no msg field, msg is the entire
+// line. A missing field never matches, except with != and !~.
+type Filter struct {
+	raw   string
+	terms []term
+}
+
+type term struct {
+	field string
+	op    string
+	val   string
+	re    *regexp.Regexp
+}
+
+// operators ordered so that longer operators are matched first.
+var operators = []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"}
+
+var levels = map[string]int{
+	"trace":   0,
+	"debug":   1,
+	"info":    2,
+	"warn":    3,
+	"warning": 3,
+	"error":   4,
+	"fatal":   5,
+	"panic":   5,
+}
+
+// ParseFilter parses the filter syntax described on Filter. An empty string
+// matches every line.
+func ParseFilter(s string) (*Filter, error) {
+	tokens, err := splitQuoted(s)
+	if err != nil {
+		return nil, err
+	}
+	f := &Filter{raw: s}
+	for _, tok := range tokens {
+		t, err := parseTerm(tok)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse term %q", tok)
+		}
+		f.terms = append(f.terms, t)
+	}
+	return f, nil
+}
+
+// String reports the filter as it was given to ParseFilter.
+func (f *Filter) String() string { return f.raw }
+
+// Match reports whether the line satisfies every term. Defaults are used for
+// any fields missing from the line, e.g. the app identified by a client
+// certificate.
+func (f *Filter) Match(line string, defaults map[string]string) bool {
+	if len(f.terms) == 0 {
+		return true
+	}
+	var fields map[string]string
+	for _, t := range f.terms {
+		if t.field == "" {
+			if !t.match(line, true) {
+				return false
+			}
+			continue
+		}
+		if fields == nil {
+			fields = parseFields(line)
+			if _, ok := fields["msg"]; !ok {
+				fields["msg"] = line
+			}
+			for k, v := range defaults {
+				if _, ok := fields[k]; !ok {
+					fields[k] = v
+				}
+			}
+		}
+		val, ok := fields[t.field]
+		if !t.match(val, ok) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTerm(tok string) (term, error) {
+	i := strings.IndexFunc(tok, func(r rune) bool { return !isFieldRune(r) })
+	if i < 0 {
+		return term{}, errors.New("missing operator")
+	}
+	t := term{field: tok[:i]}
+	rest := tok[i:]
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			t.op = op
+			t.val = rest[len(op):]
+			break
+		}
+	}
+	if t.op == "" {
+		return term{}, fmt.Errorf("unknown operator in %q", rest)
+	}
+	if t.field == "" && t.op != "~" && t.op != "!~" {
+		return term{}, errors.New("missing field")
+	}
+	if t.op == "~" || t.op == "!~" {
+		re, err := regexp.Compile(t.val)
+		if err != nil {
+			return term{}, errors.Wrap(err, "compile regexp")
+		}
+		t.re = re
+	}
+	return t, nil
+}
+
+func isFieldRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) ||
+		r == '_' || r == '-' || r == '.'
+}
+
+// match the term against a field's value. ok reports whether the field was
+// present.
+func (t term) match(val string, ok bool) bool {
+	switch t.op {
+	case "!=":
+		return !ok || val != t.val
+	case "!~":
+		return !ok || !t.re.MatchString(val)
+	}
+	if !ok {
+		return false
+	}
+	switch t.op {
+	case "=":
+		return val == t.val
+	case "~":
+		return t.re.MatchString(val)
+	}
+	cmp := compare(val, t.val)
+	switch t.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// compare reports -1, 0, or 1 as a is less than, equal to, or greater than b.
+func compare(a, b string) int {
+	la, okA := levels[strings.ToLower(a)]
+	lb, okB := levels[strings.ToLower(b)]
+	if okA && okB {
+		return compareInts(la, lb)
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseFields from a JSON object or key=value pairs. Nested JSON values are
+// reported as their JSON encoding.
+func parseFields(line string) map[string]string {
+	fields := map[string]string{}
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "{") {
+		obj := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(trimmed), &obj); err == nil {
+			for k, raw := range obj {
+				var s string
+				if err = json.Unmarshal(raw, &s); err == nil {
+					fields[k] = s
+				} else {
+					fields[k] = string(raw)
+				}
+			}
+			return fields
+		}
+	}
+	tokens, err := splitQuoted(trimmed)
+	if err != nil {
+		// Unbalanced quotes are common in plain text, so fall back
+		// to splitting on whitespace
+		tokens = strings.Fields(trimmed)
+	}
+	for _, tok := range tokens {
+		kv := strings.SplitN(tok, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			continue
+		}
+		fields[kv[0]] = kv[1]
+	}
+	return fields
+}
+
+// splitQuoted splits s on whitespace, treating double-quoted sections as part
+// of a single token. Quotes are removed, and \" escapes a quote.
+func splitQuoted(s string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote, inToken := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && inQuote && i+1 < len(s) && s[i+1] == '"':
+			cur.WriteByte('"')
+			i++
+		case c == '"':
+			inQuote = !inQuote
+			inToken = true
+		case !inQuote && (c == ' ' || c == '\t' || c == '\n'):
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteByte(c)
+			inToken = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quote")
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
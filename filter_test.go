@@ -0,0 +1,132 @@
+package sls_test
+
+import (
+	"testing"
+
+	"github.com/egtann/sls"
+)
+
+func TestParseFilter(t *testing.T) {
+	tcs := map[string]struct {
+		filter  string
+		wantErr bool
+	}{
+		"empty":               {filter: ""},
+		"equal":               {filter: "app=api"},
+		"several terms":       {filter: "app=api level>=warn msg~timeout"},
+		"whole line regexp":   {filter: "~timeout"},
+		"negated regexp":      {filter: "!~timeout"},
+		"quoted value":        {filter: `msg="connection reset"`},
+		"escaped quote":       {filter: `msg="say \"hi\""`},
+		"dotted field":        {filter: "http.status>=500"},
+		"missing operator":    {filter: "app", wantErr: true},
+		"missing field":       {filter: "=api", wantErr: true},
+		"unknown operator":    {filter: "app:api", wantErr: true},
+		"invalid regexp":      {filter: "msg~(", wantErr: true},
+		"unterminated quote":  {filter: `msg="oops`, wantErr: true},
+		"comparison no field": {filter: ">=warn", wantErr: true},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			f, err := sls.ParseFilter(tc.filter)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.String() != tc.filter {
+				t.Fatalf("expected %q, got %q", tc.filter, f.String())
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	const (
+		jsonLine   = `{"level":"error","msg":"request timeout","status":503,"app":"api"}`
+		logfmtLine = `level=info msg="request done" status=200 took=9ms`
+		plainLine  = "panic: runtime error"
+	)
+	tcs := map[string]struct {
+		filter   string
+		line     string
+		defaults map[string]string
+		want     bool
+	}{
+		"empty matches all": {filter: "", line: plainLine, want: true},
+
+		// Equality
+		"json equal":         {filter: "app=api", line: jsonLine, want: true},
+		"json not equal":     {filter: "app=web", line: jsonLine, want: false},
+		"json number":        {filter: "status=503", line: jsonLine, want: true},
+		"logfmt equal":       {filter: "level=info", line: logfmtLine, want: true},
+		"quoted value":       {filter: `msg="request done"`, line: logfmtLine, want: true},
+		"!= matches other":   {filter: "app!=web", line: jsonLine, want: true},
+		"!= rejects equal":   {filter: "app!=api", line: jsonLine, want: false},
+		"default field":      {filter: "app=api", line: logfmtLine, defaults: map[string]string{"app": "api"}, want: true},
+		"line beats default": {filter: "app=api", line: jsonLine, defaults: map[string]string{"app": "web"}, want: true},
+
+		// Regular expressions
+		"field regexp":          {filter: "msg~time.?out", line: jsonLine, want: true},
+		"field regexp no match": {filter: "msg~^timeout", line: jsonLine, want: false},
+		"negated field regexp":  {filter: "msg!~timeout", line: jsonLine, want: false},
+		"whole line regexp":     {filter: "~runtime", line: plainLine, want: true},
+		"negated line regexp":   {filter: "!~runtime", line: plainLine, want: false},
+		"msg defaults to line":  {filter: "msg~^panic", line: plainLine, want: true},
+
+		// Missing fields only match negated operators
+		"missing =":  {filter: "user=bob", line: jsonLine, want: false},
+		"missing ~":  {filter: "user~bob", line: jsonLine, want: false},
+		"missing >=": {filter: "user>=a", line: jsonLine, want: false},
+		"missing !=": {filter: "user!=bob", line: jsonLine, want: true},
+		"missing !~": {filter: "user!~bob", line: jsonLine, want: true},
+
+		// Comparisons use level order, then numeric, then string order
+		"level >= below":          {filter: "level>=warn", line: logfmtLine, want: false},
+		"level >= above":          {filter: "level>=warn", line: jsonLine, want: true},
+		"level case insensitive":  {filter: "level>=WARN", line: `level=Error`, want: true},
+		"level < ":                {filter: "level<error", line: `level=warning`, want: true},
+		"level not string order":  {filter: "level>info", line: `level=debug`, want: false},
+		"numeric not string":      {filter: "status>=500", line: `status=1000`, want: true},
+		"numeric <":               {filter: "status<500", line: logfmtLine, want: true},
+		"numeric float":           {filter: "latency>0.5", line: `latency=0.75`, want: true},
+		"string order":            {filter: "user>m", line: `user=zed`, want: true},
+		"mixed falls back string": {filter: "took<=9ms", line: logfmtLine, want: true},
+
+		// Every term must match
+		"all terms match": {
+			filter: "app=api level>=warn msg~timeout",
+			line:   jsonLine,
+			want:   true,
+		},
+		"one term fails": {
+			filter: "app=api level>=fatal msg~timeout",
+			line:   jsonLine,
+			want:   false,
+		},
+
+		// Operators are matched longest first
+		">= is not > then =": {filter: "status>=503", line: jsonLine, want: true},
+		"!= is not ! then =": {filter: "status!=503", line: jsonLine, want: false},
+		"value with operator": {
+			filter: "query=a=b",
+			line:   `query=a=b`,
+			want:   true,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			f, err := sls.ParseFilter(tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Match(tc.line, tc.defaults); got != tc.want {
+				t.Fatalf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package sls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Format determines how log entries are framed when stored or tailed. A
+// single trailing newline on an entry is treated as its terminator and isn't
+// part of the entry in any format.
+//
+// Every format stores entries byte for byte, but entries posted to the server
+// arrive as JSON strings, which must be UTF-8. Invalid UTF-8 is replaced with
+// U+FFFD while decoding the request, before any framing, so binary data
+// should be encoded (e.g. base64) by the client.
+//
+// Logfiles don't record their format, so changing the format of a directory
+// with existing logs leaves files which mix formats until they're rotated
+// out.
+type Format string
+
+const (
+	// FormatLines ends each entry with a newline. Entries which contain
+	// newlines, such as stack traces, can't be told apart from several
+	// entries when read back.
+	FormatLines Format = "lines"
+
+	// FormatEscaped writes each entry on a single line, escaping
+	// backslashes, newlines, and carriage returns with a backslash. Logs
+	// remain readable with standard tools like grep.
+	FormatEscaped Format = "escaped"
+
+	// FormatLength prefixes each entry with its length in bytes and a
+	// newline, and follows it with a newline. Any bytes, including
+	// newlines, can appear in an entry.
+	FormatLength Format = "length"
+)
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// ParseFormat reports the Format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatLines, FormatEscaped, FormatLength:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format: %s", s)
+}
+
+// Append the framed entry to buf.
+func (f Format) Append(buf []byte, entry string) []byte {
+	entry = strings.TrimSuffix(entry, "\n")
+	switch f {
+	case FormatEscaped:
+		buf = append(buf, escaper.Replace(entry)...)
+	case FormatLength:
+		buf = strconv.AppendInt(buf, int64(len(entry)), 10)
+		buf = append(buf, '\n')
+		buf = append(buf, entry...)
+	default:
+		buf = append(buf, entry...)
+	}
+	return append(buf, '\n')
+}
+
+// RecordReader reads entries framed in a Format, reassembling multiline
+// entries into single records.
+type RecordReader struct {
+	format Format
+	r      *bufio.Reader
+}
+
+// NewRecordReader reads entries in the format from r.
+func NewRecordReader(r io.Reader, format Format) *RecordReader {
+	return &RecordReader{format: format, r: bufio.NewReader(r)}
+}
+
+// RecordError reports an entry whose framing is invalid, such as an entry
+// written in a different format. The reader skips past it to the next
+// newline, so reading may continue.
+type RecordError struct {
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return "invalid record: " + e.Err.Error()
+}
+
+// Read the next entry. It reports io.EOF once every entry has been read, or
+// io.ErrUnexpectedEOF if the last entry is incomplete, e.g. because a write
+// was interrupted. An invalid entry is reported as a *RecordError.
+func (rr *RecordReader) Read() (string, error) {
+	if rr.format == FormatLength {
+		return rr.readLength()
+	}
+	line, err := rr.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	if rr.format == FormatEscaped {
+		entry, err := unescape(line)
+		if err != nil {
+			return "", &RecordError{Err: err}
+		}
+		return entry, nil
+	}
+	return line, nil
+}
+
+func (rr *RecordReader) readLength() (string, error) {
+	prefix, err := rr.r.ReadString('\n')
+	if err == io.EOF && prefix != "" {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(prefix, "\n"), 10, 64)
+	if err != nil || n < 0 {
+		err = fmt.Errorf("invalid length prefix %q", prefix)
+		return "", &RecordError{Err: err}
+	}
+
+	// Copy rather than allocating n bytes up front, since a bogus prefix
+	// can be arbitrarily large
+	var buf strings.Builder
+	if _, err = io.CopyN(&buf, rr.r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	c, err := rr.r.ReadByte()
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	if c != '\n' {
+		// Resync at the next newline so the following entry can
+		// still be read
+		if _, err = rr.r.ReadString('\n'); err != nil && err != io.EOF {
+			return "", err
+		}
+		err = errors.New("missing newline after entry")
+		return "", &RecordError{Err: err}
+	}
+	return buf.String(), nil
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", errors.New("trailing backslash")
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,164 @@
+package sls_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/egtann/sls"
+)
+
+var formats = []sls.Format{sls.FormatLines, sls.FormatEscaped, sls.FormatLength}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range formats {
+		got, err := sls.ParseFormat(string(f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != f {
+			t.Fatalf("expected %s, got %s", f, got)
+		}
+	}
+	if _, err := sls.ParseFormat("csv"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFormatAppend(t *testing.T) {
+	tcs := map[string]struct {
+		format sls.Format
+		entry  string
+		want   string
+	}{
+		"lines":                {sls.FormatLines, "a", "a\n"},
+		"lines trailing":       {sls.FormatLines, "a\n", "a\n"},
+		"escaped":              {sls.FormatEscaped, "a\nb\r\\c", `a\nb\r\\c` + "\n"},
+		"escaped trailing":     {sls.FormatEscaped, "a\n", "a\n"},
+		"escaped two trailing": {sls.FormatEscaped, "a\n\n", `a\n` + "\n"},
+		"length":               {sls.FormatLength, "a\nb", "3\na\nb\n"},
+		"length trailing":      {sls.FormatLength, "ab\n", "2\nab\n"},
+		"length empty":         {sls.FormatLength, "", "0\n\n"},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			got := string(tc.format.Append(nil, tc.entry))
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRecordReaderRoundTrip(t *testing.T) {
+	entries := []string{
+		"plain",
+		"multi\nline\nstack trace",
+		"carriage\r\nreturn",
+		`back\slash and \n literal`,
+		`C:\Users\sls`,
+		"trailing newline\n",
+		"",
+		"nul\x00byte",
+	}
+	for _, f := range []sls.Format{sls.FormatEscaped, sls.FormatLength} {
+		t.Run(string(f), func(t *testing.T) {
+			var buf []byte
+			for _, e := range entries {
+				buf = f.Append(buf, e)
+			}
+			rr := sls.NewRecordReader(bytes.NewReader(buf), f)
+			for _, e := range entries {
+				want := e
+				if want == "trailing newline\n" {
+					want = "trailing newline"
+				}
+				got, err := rr.Read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Fatalf("expected %q, got %q", want, got)
+				}
+			}
+			if _, err := rr.Read(); err != io.EOF {
+				t.Fatalf("expected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordReaderLines(t *testing.T) {
+	rr := sls.NewRecordReader(bytes.NewBufferString("a\nb\n"), sls.FormatLines)
+	for _, want := range []string{"a", "b"} {
+		got, err := rr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+	if _, err := rr.Read(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestRecordReaderPartial(t *testing.T) {
+	tcs := map[string]struct {
+		format sls.Format
+		data   string
+	}{
+		"lines":         {sls.FormatLines, "a\nb"},
+		"escaped":       {sls.FormatEscaped, "a\nb"},
+		"length prefix": {sls.FormatLength, "1\na\n2"},
+		"length entry":  {sls.FormatLength, "1\na\n5\nab"},
+		"length end":    {sls.FormatLength, "1\na\n2\nab"},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			rr := sls.NewRecordReader(bytes.NewBufferString(tc.data), tc.format)
+			if got, err := rr.Read(); err != nil || got != "a" {
+				t.Fatalf("expected a, got %q: %v", got, err)
+			}
+			if _, err := rr.Read(); err != io.ErrUnexpectedEOF {
+				t.Fatalf("expected unexpected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordReaderInvalid(t *testing.T) {
+	tcs := map[string]struct {
+		format sls.Format
+		data   string
+	}{
+		"bad prefix":         {sls.FormatLength, "abc\n"},
+		"negative prefix":    {sls.FormatLength, "-1\n"},
+		"missing newline":    {sls.FormatLength, "1\nab\n"},
+		"bad escape":         {sls.FormatEscaped, `C:\Users` + "\n"},
+		"trailing backslash": {sls.FormatEscaped, `a\` + "\n"},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			// A valid entry follows each invalid one, which should
+			// still be read
+			data := tc.data + string(tc.format.Append(nil, "ok"))
+			rr := sls.NewRecordReader(bytes.NewBufferString(data), tc.format)
+			_, err := rr.Read()
+			if _, ok := err.(*sls.RecordError); !ok {
+				t.Fatalf("expected record error, got %v", err)
+			}
+			entry, err := rr.Read()
+			if err != nil {
+				t.Fatalf("expected entry after invalid record, got %v", err)
+			}
+			if entry != "ok" {
+				t.Fatalf("expected ok, got %q", entry)
+			}
+			if _, err = rr.Read(); err != io.EOF {
+				t.Fatalf("expected EOF, got %v", err)
+			}
+		})
+	}
+}
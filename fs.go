@@ -0,0 +1,72 @@
+package sls
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is a writable filesystem in which logfiles are stored. Names are
+// slash-separated and relative to the root of the FS, following the io/fs
+// conventions.
+type FS interface {
+	fs.ReadDirFS
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	Remove(name string) error
+}
+
+// File is a writable file opened from an FS. It's satisfied by *os.File.
+type File interface {
+	io.WriteCloser
+	Name() string
+}
+
+// DirFS is an FS rooted at a directory on the local disk.
+type DirFS string
+
+// Open the named file for reading.
+func (d DirFS) Open(name string) (fs.File, error) {
+	return os.DirFS(string(d)).Open(name)
+}
+
+// ReadDir reads the named directory and reports its entries sorted by
+// filename.
+func (d DirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	pth, err := d.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(pth)
+}
+
+// OpenFile opens the named file with the given flags, as in os.OpenFile.
+func (d DirFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	pth, err := d.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.OpenFile(pth, flag, perm)
+	if err != nil {
+		// Avoid returning a non-nil File holding a nil *os.File
+		return nil, err
+	}
+	return fi, nil
+}
+
+// Remove the named file.
+func (d DirFS) Remove(name string) error {
+	pth, err := d.join("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(pth)
+}
+
+// join validates name and converts it to a path on the local disk.
+func (d DirFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(string(d), filepath.FromSlash(name)), nil
+}
@@ -8,4 +8,4 @@ require (
 	github.com/pkg/errors v0.8.1
 )
 
-go 1.13
+go 1.19
@@ -0,0 +1,119 @@
+package sls
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// heartbeatType identifies heartbeat entries among other logs.
+const heartbeatType = "sls_heartbeat"
+
+// Heartbeat is a small entry which clients log periodically. Since heartbeats
+// travel with other logs, a missing heartbeat indicates that logs were lost
+// rather than that the app was quiet. Instance distinguishes replicas of the
+// same app, whose heartbeats would otherwise hide each other's gaps.
+type Heartbeat struct {
+	Type       string    `json:"type"`
+	App        string    `json:"app"`
+	Instance   string    `json:"instance,omitempty"`
+	Time       time.Time `json:"time"`
+	IntervalMS int64     `json:"interval_ms"`
+}
+
+// Gap is a period in which heartbeats from an instance of an app stopped. If
+// the heartbeats haven't resumed, Ongoing is true and End is the time the gap
+// was found.
+type Gap struct {
+	Instance string    `json:"instance,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Ongoing  bool      `json:"ongoing,omitempty"`
+}
+
+// NewHeartbeat reports a heartbeat entry for the instance of the app, which
+// will emit another after the interval.
+func NewHeartbeat(
+	app, instance string,
+	t time.Time,
+	interval time.Duration,
+) string {
+	byt, _ := json.Marshal(Heartbeat{
+		Type:       heartbeatType,
+		App:        app,
+		Instance:   instance,
+		Time:       t.UTC(),
+		IntervalMS: interval.Milliseconds(),
+	})
+	return string(byt)
+}
+
+// ParseHeartbeat reports whether the entry is a heartbeat and, if so, the
+// heartbeat.
+func ParseHeartbeat(entry string) (Heartbeat, bool) {
+	// Avoid decoding JSON for the vast majority of entries, which aren't
+	// heartbeats
+	if !strings.Contains(entry, heartbeatType) {
+		return Heartbeat{}, false
+	}
+	var hb Heartbeat
+	if err := json.Unmarshal([]byte(entry), &hb); err != nil {
+		return Heartbeat{}, false
+	}
+	if hb.Type != heartbeatType || hb.IntervalMS <= 0 {
+		return Heartbeat{}, false
+	}
+	return hb, true
+}
+
+// Interval between the heartbeat and the next one from the same app.
+func (hb Heartbeat) Interval() time.Duration {
+	return time.Duration(hb.IntervalMS) * time.Millisecond
+}
+
+// FindGaps reports every period in which the time between heartbeats from an
+// instance was more than twice the expected interval, allowing for jitter and
+// flush delays. Heartbeats needn't be sorted, and beats isn't modified. If an
+// instance's last heartbeat is overdue at now, its final gap is ongoing, so an
+// instance which was shut down permanently reports an ongoing gap until its
+// heartbeats age out of the logs. Gaps are sorted by start time.
+func FindGaps(beats []Heartbeat, now time.Time) []Gap {
+	beats = append([]Heartbeat(nil), beats...)
+	sort.Slice(beats, func(i, j int) bool {
+		return beats[i].Time.Before(beats[j].Time)
+	})
+	byInstance := map[string][]Heartbeat{}
+	for _, hb := range beats {
+		byInstance[hb.Instance] = append(byInstance[hb.Instance], hb)
+	}
+	gaps := []Gap{}
+	for instance, group := range byInstance {
+		for i := 1; i < len(group); i++ {
+			prev, cur := group[i-1], group[i]
+			if cur.Time.Sub(prev.Time) > 2*prev.Interval() {
+				gaps = append(gaps, Gap{
+					Instance: instance,
+					Start:    prev.Time,
+					End:      cur.Time,
+				})
+			}
+		}
+		last := group[len(group)-1]
+		if now.Sub(last.Time) > 2*last.Interval() {
+			gaps = append(gaps, Gap{
+				Instance: instance,
+				Start:    last.Time,
+				End:      now.UTC(),
+				Ongoing:  true,
+			})
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].Start.Equal(gaps[j].Start) {
+			return gaps[i].Instance < gaps[j].Instance
+		}
+		return gaps[i].Start.Before(gaps[j].Start)
+	})
+	return gaps
+}
@@ -0,0 +1,72 @@
+package sls_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+)
+
+func TestParseHeartbeat(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	hb, ok := sls.ParseHeartbeat(sls.NewHeartbeat("app", "a", now, time.Minute))
+	if !ok {
+		t.Fatal("expected heartbeat")
+	}
+	if hb.App != "app" || hb.Instance != "a" || !hb.Time.Equal(now) ||
+		hb.Interval() != time.Minute {
+		t.Fatalf("unexpected heartbeat %+v", hb)
+	}
+	for _, entry := range []string{
+		"hello",
+		`{"type":"other","app":"app","interval_ms":1000}`,
+		`{"type":"sls_heartbeat","app":"app"}`,
+	} {
+		if _, ok = sls.ParseHeartbeat(entry); ok {
+			t.Fatalf("expected %q not to be a heartbeat", entry)
+		}
+	}
+}
+
+func TestFindGaps(t *testing.T) {
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	at := func(instance string, min int) sls.Heartbeat {
+		return sls.Heartbeat{
+			App:        "app",
+			Instance:   instance,
+			Time:       start.Add(time.Duration(min) * time.Minute),
+			IntervalMS: time.Minute.Milliseconds(),
+		}
+	}
+
+	// Instance b stops between minutes 1 and 5 while a keeps beating,
+	// which would hide the gap if heartbeats weren't grouped by instance
+	beats := []sls.Heartbeat{
+		at("a", 0), at("b", 0), at("a", 1), at("b", 1), at("a", 2),
+		at("a", 3), at("a", 4), at("a", 5), at("b", 5), at("a", 6),
+	}
+	now := start.Add(10 * time.Minute)
+
+	// Pass the heartbeats in reverse, which FindGaps shouldn't sort in
+	// place
+	for i, j := 0, len(beats)-1; i < j; i, j = i+1, j-1 {
+		beats[i], beats[j] = beats[j], beats[i]
+	}
+	got := sls.FindGaps(beats, now)
+	if beats[0] != at("a", 6) {
+		t.Fatal("expected heartbeats to be left unsorted")
+	}
+	want := []sls.Gap{
+		{Instance: "b", Start: at("b", 1).Time, End: at("b", 5).Time},
+		{Instance: "b", Start: at("b", 5).Time, End: now, Ongoing: true},
+		{Instance: "a", Start: at("a", 6).Time, End: now, Ongoing: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("gap %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+type debugState struct {
+	Goroutines int          `json:"goroutines"`
+	NextShard  uint64       `json:"next_shard"`
+	Shards     []shardState `json:"shards"`
+	Tails      []tailState  `json:"tails"`
+}
+
+type shardState struct {
+	File string `json:"file"`
+
+	// Pending writes waiting on or holding the shard's lock. A number
+	// that keeps growing indicates a stuck write.
+	Pending int64 `json:"pending"`
+}
+
+type tailState struct {
+	Filter string `json:"filter"`
+
+	// Depth of the subscriber's buffer. Once it reaches tailBuffer, new
+	// lines are dropped.
+	Depth   int    `json:"depth"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// AdminMux serves pprof, expvar, and a /debug/state dump of the service for
+// diagnosing leaks and stuck writes in production. None of these endpoints
+// are authenticated, so the mux should only be exposed on a private port.
+func (srv *Service) AdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/state", srv.handleDebugState)
+	return mux
+}
+
+// handleDebugState reports the current state without taking any shard
+// locks, so it responds even when a write is wedged.
+func (srv *Service) handleDebugState(w http.ResponseWriter, r *http.Request) {
+	state := debugState{
+		Goroutines: runtime.NumGoroutine(),
+		NextShard:  srv.next.Load() % uint64(len(srv.shards)),
+		Tails:      srv.tails.state(),
+	}
+	for _, s := range srv.shards {
+		state.Shards = append(state.Shards, shardState{
+			File:    s.name.Load().(string),
+			Pending: s.pending.Load(),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		srv.log.Printf("failed to encode debug state: %s\n", err)
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+func TestHandleDebugState(t *testing.T) {
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	srv := newTestService(t, clock, &slstest.FS{}, &slstest.FS{})
+	filter, err := sls.ParseFilter("level=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := srv.tails.subscribe(filter)
+	defer srv.tails.unsubscribe(sub)
+	logs := make([]string, tailBuffer+2)
+	for i := range logs {
+		logs[i] = "level=error"
+	}
+	srv.tails.publish("", append(logs, "level=info"))
+	srv.next.Add(3)
+	srv.shards[1].pending.Add(1)
+	defer srv.shards[1].pending.Add(-1)
+
+	w := httptest.NewRecorder()
+	srv.AdminMux().ServeHTTP(w, httptest.NewRequest("GET", "/debug/state", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var state struct {
+		Goroutines int    `json:"goroutines"`
+		NextShard  uint64 `json:"next_shard"`
+		Shards     []struct {
+			File    string `json:"file"`
+			Pending int64  `json:"pending"`
+		} `json:"shards"`
+		Tails []struct {
+			Filter  string `json:"filter"`
+			Depth   int    `json:"depth"`
+			Dropped uint64 `json:"dropped"`
+		} `json:"tails"`
+	}
+	if err = json.NewDecoder(w.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Goroutines <= 0 {
+		t.Fatalf("expected goroutines, got %d", state.Goroutines)
+	}
+	if state.NextShard != 1 {
+		t.Fatalf("expected next shard 1, got %d", state.NextShard)
+	}
+	if len(state.Shards) != 2 {
+		t.Fatalf("expected 2 shards, got %+v", state.Shards)
+	}
+	for i, s := range state.Shards {
+		if s.File != "20261014.log" {
+			t.Fatalf("shard %d: expected 20261014.log, got %s", i, s.File)
+		}
+		if want := int64(i); s.Pending != want {
+			t.Fatalf("shard %d: expected %d pending, got %d", i, want, s.Pending)
+		}
+	}
+	if len(state.Tails) != 1 {
+		t.Fatalf("expected 1 tail, got %+v", state.Tails)
+	}
+	tail := state.Tails[0]
+	if tail.Filter != filter.String() || tail.Depth != tailBuffer ||
+		tail.Dropped != 2 {
+		t.Fatalf("unexpected tail %+v", tail)
+	}
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type ctxKey int
+
+const appKey ctxKey = iota
+
+// WithAllowedNets restricts posting logs to clients with an IP in one of the
+// networks. If none are given, any IP may post logs. Reading logs through
+// GET /tail and GET /gaps requires only the API key.
+func (srv *Service) WithAllowedNets(nets []*net.IPNet) *Service {
+	srv.allowedNets = nets
+	return srv
+}
+
+// WithClientCerts requires clients posting logs to present a TLS certificate
+// signed by one of the server's configured client CAs. The certificate's
+// common name identifies the app sending logs. As with WithAllowedNets,
+// reading logs doesn't require a certificate.
+//
+// The server's tls.Config should use tls.VerifyClientCertIfGiven rather than
+// requiring certificates on every connection, so that health checks from
+// load balancers don't need one.
+func (srv *Service) WithClientCerts() *Service {
+	srv.requireClientCert = true
+	return srv
+}
+
+// appFromContext reports the app identified by a client certificate, if any.
+func appFromContext(ctx context.Context) string {
+	app, _ := ctx.Value(appKey).(string)
+	return app
+}
+
+func (srv *Service) isAllowedIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(srv.allowedNets) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		ip := net.ParseIP(host)
+		for _, n := range srv.allowedNets {
+			if ip != nil && n.Contains(ip) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		srv.log.Printf("rejected ip %s\n", host)
+		http.NotFound(w, r)
+	})
+}
+
+// hasClientCert ensures the client presented a verified certificate when
+// WithClientCerts is set, and adds the certificate's common name to the
+// request context as the app.
+func (srv *Service) hasClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !srv.requireClientCert {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// VerifiedChains is only populated once the certificate has been
+		// verified against the client CAs, unlike PeerCertificates
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		app := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		ctx := context.WithValue(r.Context(), appKey, app)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+func TestIsAllowedIP(t *testing.T) {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "2001:db8::/32"} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nets = append(nets, n)
+	}
+	tcs := map[string]struct {
+		remoteAddr string
+		want       int
+	}{
+		"inside":      {remoteAddr: "10.1.2.3:1234", want: http.StatusOK},
+		"outside":     {remoteAddr: "192.168.1.1:1234", want: http.StatusNotFound},
+		"ipv6 inside": {remoteAddr: "[2001:db8::1]:1234", want: http.StatusOK},
+		"ipv6 outside": {
+			remoteAddr: "[2001:db9::1]:1234",
+			want:       http.StatusNotFound,
+		},
+		"ipv4-mapped": {remoteAddr: "[::ffff:10.1.2.3]:1234", want: http.StatusOK},
+		"no port":     {remoteAddr: "10.1.2.3", want: http.StatusNotFound},
+		"not an ip":   {remoteAddr: "example.com:1234", want: http.StatusNotFound},
+	}
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	srv := newTestService(t, clock, &slstest.FS{}).WithAllowedNets(nets)
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/log", strings.NewReader(`["a"]`))
+			req.Header.Set("X-API-Key", "k")
+			req.RemoteAddr = tc.remoteAddr
+			w := httptest.NewRecorder()
+			srv.Mux.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, w.Code)
+			}
+		})
+	}
+
+	// Reading logs isn't restricted by IP
+	req := httptest.NewRequest("GET", "/gaps?app=web", nil)
+	req.Header.Set("X-API-Key", "k")
+	req.RemoteAddr = "192.168.1.1:1234"
+	w := httptest.NewRecorder()
+	srv.Mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected gaps from outside the networks, got %d", w.Code)
+	}
+}
+
+func TestHasClientCert(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "web"}}
+	tcs := map[string]struct {
+		tls  *tls.ConnectionState
+		want int
+	}{
+		"no tls": {want: http.StatusNotFound},
+		"no cert": {
+			tls:  &tls.ConnectionState{},
+			want: http.StatusNotFound,
+		},
+		"unverified cert": {
+			tls: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert},
+			},
+			want: http.StatusNotFound,
+		},
+		"verified cert": {
+			tls: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{cert},
+				VerifiedChains:   [][]*x509.Certificate{{cert}},
+			},
+			want: http.StatusOK,
+		},
+	}
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	srv := newTestService(t, clock, &slstest.FS{}).WithClientCerts()
+
+	// The verified common name becomes the app, which tail filters match
+	filter, err := sls.ParseFilter("app=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := srv.tails.subscribe(filter)
+	defer srv.tails.unsubscribe(sub)
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/log", strings.NewReader(`["a"]`))
+			req.Header.Set("X-API-Key", "k")
+			req.TLS = tc.tls
+			w := httptest.NewRecorder()
+			srv.Mux.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			select {
+			case l := <-sub.ch:
+				if l != "a" {
+					t.Fatalf("expected a, got %q", l)
+				}
+			default:
+				t.Fatal("expected log from app web")
+			}
+		})
+	}
+	if len(sub.ch) != 0 {
+		t.Fatalf("expected only verified logs, got %d", len(sub.ch))
+	}
+
+	// Reading logs doesn't need a certificate
+	req := httptest.NewRequest("GET", "/gaps?app=web", nil)
+	req.Header.Set("X-API-Key", "k")
+	w := httptest.NewRecorder()
+	srv.Mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected gaps without a certificate, got %d", w.Code)
+	}
+}
+
+func TestAppFromContext(t *testing.T) {
+	var got string
+	h := (&Service{requireClientCert: true}).hasClientCert(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = appFromContext(r.Context())
+		}))
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "web"}}
+	req := httptest.NewRequest("POST", "/log", nil)
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "web" {
+		t.Fatalf("expected app web, got %q", got)
+	}
+	if app := appFromContext(httptest.NewRequest("GET", "/", nil).Context()); app != "" {
+		t.Fatalf("expected no app, got %q", app)
+	}
+}
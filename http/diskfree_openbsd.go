@@ -0,0 +1,13 @@
+package http
+
+import "syscall"
+
+// diskFree reports the bytes available to unprivileged users on the disk
+// containing dir.
+func diskFree(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.F_bavail) * uint64(st.F_bsize), nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !openbsd
+// +build !linux,!darwin,!freebsd,!openbsd
+
+package http
+
+// diskFree is not supported on this platform, so the disk check is skipped.
+func diskFree(dir string) (uint64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/pkg/errors"
+)
+
+// gapsWindow is how far back GET /gaps looks when since isn't given, so a
+// report doesn't scan every retained logfile.
+const gapsWindow = 24 * time.Hour
+
+type gapsReport struct {
+	App        string    `json:"app"`
+	Heartbeats int       `json:"heartbeats"`
+	Gaps       []sls.Gap `json:"gaps"`
+}
+
+// handleGaps reports periods in which heartbeats from each instance of an app
+// stopped, using every retained logfile across all shards. The app query
+// parameter is required. The optional since parameter, in RFC 3339 format,
+// skips heartbeats before that time. It defaults to gapsWindow ago, so an
+// instance which stopped before then isn't reported. Entries with invalid
+// framing are skipped.
+func (srv *Service) handleGaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+	app := r.URL.Query().Get("app")
+	if app == "" {
+		http.Error(w, "missing app", http.StatusBadRequest)
+		return
+	}
+	since := srv.clock.Now().Add(-gapsWindow)
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	srv.log.Printf("finding gaps for %s\n", app)
+	beats := []sls.Heartbeat{}
+	for i, s := range srv.shards {
+		tmp, err := s.heartbeats(srv.format, app, since)
+		if err != nil {
+			err = errors.Wrapf(err, "heartbeats in shard %d", i)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		beats = append(beats, tmp...)
+	}
+
+	// Heartbeats from each shard are interleaved in time, so FindGaps
+	// sorts them before comparing
+	report := gapsReport{
+		App:        app,
+		Heartbeats: len(beats),
+		Gaps:       sls.FindGaps(beats, srv.clock.Now()),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		srv.log.Printf("failed to encode gaps: %s\n", err)
+	}
+}
+
+// heartbeats reports every heartbeat from the app at or after since in the
+// shard's logfiles.
+func (s *shard) heartbeats(
+	format sls.Format,
+	app string,
+	since time.Time,
+) ([]sls.Heartbeat, error) {
+	files, err := getFilesInDir(s.fsys, ".log")
+	if err != nil {
+		return nil, errors.Wrap(err, "get files in dir")
+	}
+	beats := []sls.Heartbeat{}
+	for _, fi := range files {
+		// Skip files from days before since
+		name := strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))
+		day, err := time.Parse("20060102", name)
+		if err != nil {
+			s.log.Printf("skipping %s: not a logfile\n", fi.Name())
+			continue
+		}
+		if day.Add(24 * time.Hour).Before(since) {
+			continue
+		}
+		tmp, err := s.readHeartbeats(fi.Name(), format, app, since)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", fi.Name())
+		}
+		beats = append(beats, tmp...)
+	}
+	return beats, nil
+}
+
+func (s *shard) readHeartbeats(
+	name string,
+	format sls.Format,
+	app string,
+	since time.Time,
+) ([]sls.Heartbeat, error) {
+	fi, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "open")
+	}
+	defer fi.Close()
+	beats := []sls.Heartbeat{}
+	skipped := 0
+	defer func() {
+		if skipped > 0 {
+			s.log.Printf("skipped %d invalid records in %s\n",
+				skipped, name)
+		}
+	}()
+	rr := sls.NewRecordReader(fi, format)
+	for {
+		entry, err := rr.Read()
+		if _, ok := err.(*sls.RecordError); ok {
+			// Logfiles written before a format change hold
+			// entries in the old format. The reader resyncs at
+			// the next newline, so skip them.
+			skipped++
+			continue
+		}
+		switch {
+		case err == io.EOF:
+			return beats, nil
+		case err == io.ErrUnexpectedEOF:
+			// The current logfile may end in a partial entry
+			// that's still being written
+			return beats, nil
+		case err != nil:
+			return nil, err
+		}
+		hb, ok := sls.ParseHeartbeat(entry)
+		if !ok || hb.App != app || hb.Time.Before(since) {
+			continue
+		}
+		beats = append(beats, hb)
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+func TestHandleGapsSkipsInvalidRecords(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	beat := func(min int) string {
+		at := now.Add(time.Duration(min-10) * time.Minute)
+		return sls.NewHeartbeat("app", "a", at, time.Minute)
+	}
+
+	// The logfile was written as lines before the server switched to
+	// escaped, so the path is an invalid escape
+	lines := []string{beat(0), `opening C:\Users\me`, beat(1), beat(7), beat(9)}
+	fsys := slstest.NewFS(map[string]string{
+		"20261014.log": strings.Join(lines, "\n") + "\n",
+	})
+	clock := slstest.NewClock(now)
+	srv, err := NewService(&testLogger{t}, []sls.FS{fsys}, clock, "k", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+	srv.WithFormat(sls.FormatEscaped)
+
+	req := httptest.NewRequest("GET", "/gaps?app=app", nil)
+	req.Header.Set("X-API-Key", "k")
+	w := httptest.NewRecorder()
+	srv.Mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var report gapsReport
+	if err = json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Heartbeats != 4 {
+		t.Fatalf("expected 4 heartbeats, got %d", report.Heartbeats)
+	}
+	if len(report.Gaps) != 1 || report.Gaps[0].Instance != "a" ||
+		report.Gaps[0].Ongoing {
+		t.Fatalf("expected one gap, got %+v", report.Gaps)
+	}
+}
+
+func TestHandleGapsWindow(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	beat := func(at time.Time) string {
+		return sls.NewHeartbeat("app", "a", at, time.Minute) + "\n"
+	}
+	old := now.Add(-10 * 24 * time.Hour)
+	fsys := slstest.NewFS(map[string]string{
+		"20261004.log": beat(old),
+		"20261014.log": beat(now.Add(-time.Minute)),
+		"notes.log":    "not a logfile\n",
+	})
+	clock := slstest.NewClock(now)
+	srv := newTestService(t, clock, fsys)
+	tcs := map[string]struct {
+		query string
+		want  int
+	}{
+		"default window": {query: "", want: 1},
+		"since":          {query: "&since=2026-10-01T00:00:00Z", want: 2},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/gaps?app=app"+tc.query, nil)
+			req.Header.Set("X-API-Key", "k")
+			w := httptest.NewRecorder()
+			srv.Mux.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+			}
+			var report gapsReport
+			err := json.NewDecoder(w.Body).Decode(&report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Heartbeats != tc.want {
+				t.Fatalf("expected %d heartbeats, got %d",
+					tc.want, report.Heartbeats)
+			}
+		})
+	}
+}
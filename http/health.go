@@ -0,0 +1,188 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/pkg/errors"
+)
+
+// writerTimeout is how long a write may hold the lock before the health check
+// reports the writer as wedged.
+const writerTimeout = 5 * time.Second
+
+const (
+	statusOK      = "ok"
+	statusFail    = "fail"
+	statusSkipped = "skipped"
+)
+
+var (
+	// errUnsupported is reported by platforms or filesystems where sls
+	// cannot determine free disk space.
+	errUnsupported = errors.New("unsupported on this platform")
+
+	// errDisabled is reported by checks which aren't configured.
+	errDisabled = errors.New("disabled")
+)
+
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks"`
+}
+
+type healthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// WithMinFreeBytes sets the minimum free space required on the log directory's
+// disk for the health check to pass. A value of 0 disables the check.
+func (srv *Service) WithMinFreeBytes(n uint64) *Service {
+	srv.minFreeBytes = n
+	return srv
+}
+
+// handleHealth runs each health check and reports the results as JSON. If any
+// check fails, the response is 503 so load balancers can take the node out of
+// rotation.
+func (srv *Service) handleHealth(w http.ResponseWriter, r *http.Request) {
+	srv.log.Printf("health checked\n")
+	report := srv.checkHealth()
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != statusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		srv.log.Printf("failed to encode health report: %s\n", err)
+	}
+}
+
+func (srv *Service) checkHealth() *healthReport {
+	report := &healthReport{
+		Status: statusOK,
+		Checks: map[string]healthCheck{
+			"dir_writable": newHealthCheck(srv.checkShards(checkDirWritable)),
+			"disk_free":    newHealthCheck(srv.checkShards(srv.checkDiskFree)),
+			"writer":       newHealthCheck(srv.checkShards(checkWriter)),
+		},
+	}
+	for _, c := range report.Checks {
+		if c.Status == statusFail {
+			report.Status = statusFail
+			break
+		}
+	}
+	return report
+}
+
+func newHealthCheck(detail string, err error) healthCheck {
+	switch {
+	case err == errUnsupported, err == errDisabled:
+		return healthCheck{Status: statusSkipped, Detail: err.Error()}
+	case err != nil:
+		return healthCheck{Status: statusFail, Detail: err.Error()}
+	default:
+		return healthCheck{Status: statusOK, Detail: detail}
+	}
+}
+
+// checkShards runs the check against every shard concurrently and combines
+// the results, labeling each by shard index when there's more than one. The
+// combined check fails if any shard fails, and it's skipped only if every
+// shard skipped it.
+func (srv *Service) checkShards(
+	check func(*shard) (string, error),
+) (string, error) {
+	if len(srv.shards) == 1 {
+		return check(srv.shards[0])
+	}
+	details := make([]string, len(srv.shards))
+	errs := make([]error, len(srv.shards))
+	var wg sync.WaitGroup
+	for i, s := range srv.shards {
+		wg.Add(1)
+		go func(i int, s *shard) {
+			defer wg.Done()
+			details[i], errs[i] = check(s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var failed, ok []string
+	var skipErr error
+	for i, err := range errs {
+		switch {
+		case err == errUnsupported, err == errDisabled:
+			skipErr = err
+		case err != nil:
+			failed = append(failed, fmt.Sprintf("shard %d: %s", i, err))
+		case details[i] != "":
+			ok = append(ok, fmt.Sprintf("shard %d: %s", i, details[i]))
+		}
+	}
+	if len(failed) > 0 {
+		return "", errors.New(strings.Join(failed, "; "))
+	}
+	if skipErr != nil && len(ok) == 0 {
+		return "", skipErr
+	}
+	return strings.Join(ok, "; "), nil
+}
+
+// checkDirWritable creates, writes, and removes a hidden file in the log
+// directory. Hidden files are skipped by retention, so a leftover file from a
+// crash mid-check is harmless.
+func checkDirWritable(s *shard) (string, error) {
+	name := fmt.Sprintf(".health-%d", rand.Int63())
+	fi, err := s.fsys.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", errors.Wrap(err, "create temp file")
+	}
+	defer s.fsys.Remove(name)
+	if _, err = fi.Write([]byte("OK")); err != nil {
+		fi.Close()
+		return "", errors.Wrap(err, "write temp file")
+	}
+	if err = fi.Close(); err != nil {
+		return "", errors.Wrap(err, "close temp file")
+	}
+	return "", nil
+}
+
+func (srv *Service) checkDiskFree(s *shard) (string, error) {
+	if srv.minFreeBytes == 0 {
+		return "", errDisabled
+	}
+	dir, ok := s.fsys.(sls.DirFS)
+	if !ok {
+		return "", errUnsupported
+	}
+	free, err := diskFree(string(dir))
+	if err != nil {
+		return "", err
+	}
+	detail := fmt.Sprintf("%d bytes free", free)
+	if free < srv.minFreeBytes {
+		return "", fmt.Errorf("%s, need %d", detail, srv.minFreeBytes)
+	}
+	return detail, nil
+}
+
+// checkWriter ensures no write has held the shard's lock longer than
+// writerTimeout. If one has, it's stuck (e.g. on a hung disk) and every
+// subsequent request will block behind it. The check never waits on the lock
+// itself, so it responds immediately even when the shard is wedged.
+func checkWriter(s *shard) (string, error) {
+	if held := s.lockHeldFor(); held > writerTimeout {
+		return "", fmt.Errorf("write lock held for %s", held.Round(time.Second))
+	}
+	return "", nil
+}
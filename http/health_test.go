@@ -0,0 +1,117 @@
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+func getHealth(t *testing.T, srv *Service) (int, healthReport) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Mux.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	var report healthReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, report
+}
+
+func TestHandleHealth(t *testing.T) {
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	srv := newTestService(t, clock, &slstest.FS{})
+
+	// The disk check is skipped without a minimum and on an in-memory
+	// FS, which doesn't fail the report
+	code, report := getHealth(t, srv)
+	if code != http.StatusOK || report.Status != statusOK {
+		t.Fatalf("expected ok, got %d %+v", code, report)
+	}
+	if got := report.Checks["disk_free"].Status; got != statusSkipped {
+		t.Fatalf("expected disk_free skipped, got %s", got)
+	}
+	for _, name := range []string{"dir_writable", "writer"} {
+		if got := report.Checks[name].Status; got != statusOK {
+			t.Fatalf("expected %s ok, got %s", name, got)
+		}
+	}
+
+	// A wedged writer fails only its own check, but that's enough to
+	// take the node out of rotation
+	srv.shards[0].lock()
+	defer srv.shards[0].unlock()
+	clock.Advance(writerTimeout + time.Second)
+	code, report = getHealth(t, srv)
+	if code != http.StatusServiceUnavailable || report.Status != statusFail {
+		t.Fatalf("expected 503 fail, got %d %+v", code, report)
+	}
+	if got := report.Checks["writer"].Status; got != statusFail {
+		t.Fatalf("expected writer fail, got %s", got)
+	}
+	if got := report.Checks["dir_writable"].Status; got != statusOK {
+		t.Fatalf("expected dir_writable ok, got %s", got)
+	}
+}
+
+func TestCheckWriter(t *testing.T) {
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	s := newTestShard(t, &slstest.FS{}, clock)
+	if _, err := checkWriter(s); err != nil {
+		t.Fatalf("expected unlocked writer to pass, got %s", err)
+	}
+	s.lock()
+	clock.Advance(writerTimeout)
+	if _, err := checkWriter(s); err != nil {
+		t.Fatalf("expected writer within timeout to pass, got %s", err)
+	}
+	clock.Advance(time.Second)
+	if _, err := checkWriter(s); err == nil {
+		t.Fatal("expected wedged writer to fail")
+	}
+	s.unlock()
+	if _, err := checkWriter(s); err != nil {
+		t.Fatalf("expected released writer to pass, got %s", err)
+	}
+}
+
+func TestCheckDiskFree(t *testing.T) {
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	s := newTestShard(t, sls.DirFS(t.TempDir()), clock)
+	tcs := map[string]struct {
+		min     uint64
+		wantErr bool
+	}{
+		"above minimum": {min: 1},
+		"below minimum": {min: math.MaxUint64, wantErr: true},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			srv := &Service{minFreeBytes: tc.min}
+			_, err := srv.checkDiskFree(s)
+			if err == errUnsupported {
+				t.Skip(err)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	// Checks are skipped when disabled or on filesystems other than a
+	// directory
+	srv := &Service{}
+	if _, err := srv.checkDiskFree(s); err != errDisabled {
+		t.Fatalf("expected disabled, got %v", err)
+	}
+	srv.minFreeBytes = 1
+	mem := newTestShard(t, &slstest.FS{}, clock)
+	if _, err := srv.checkDiskFree(mem); err != errUnsupported {
+		t.Fatalf("expected unsupported, got %v", err)
+	}
+}
@@ -3,15 +3,16 @@ package http
 import (
 	"crypto/subtle"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/egtann/sls"
@@ -22,52 +23,99 @@ import (
 type Service struct {
 	Mux *http.ServeMux
 
-	dir     string
-	apiKey  string
-	log     sls.Logger
-	logfile *sls.Logfile
+	apiKey string
+	log    sls.Logger
+	clock  sls.Clock
+	shards []*shard
+	tails  tails
 
-	// mu protects changes to the logfile when rotating or writing to it.
-	mu sync.Mutex
+	// format of entries when written to logfiles.
+	format sls.Format
+
+	// next is the index of the shard to receive the next batch.
+	next atomic.Uint64
+
+	// allowedNets optionally restricts which IPs may post logs.
+	allowedNets []*net.IPNet
+
+	// requireClientCert to post logs. See WithClientCerts.
+	requireClientCert bool
+
+	// minFreeBytes is the free disk space required for the health check
+	// to pass. If zero, the disk check is skipped.
+	minFreeBytes uint64
 }
 
 // NewService prepares handlers to support health and version checks as well as
 // to receive and tail out logs. The sls.Logger is for internal logging
 // purposes and does not affect the logs being aggregated or tailed out.
+//
+// Logs are stored across the shards, typically directories on different
+// disks, with each batch written round-robin to a single shard. Entries carry
+// no timestamp of their own, so the order of batches across shards can't be
+// rebuilt when reading the logfiles back; only the order within each shard is
+// kept. The clock determines when logs are rotated and deleted.
 func NewService(
 	log sls.Logger,
-	dir, apiKey string,
+	shards []sls.FS,
+	clock sls.Clock,
+	apiKey string,
 	version []byte,
 ) (*Service, error) {
-	logfile, err := sls.NewLogfile(dir)
-	if err != nil {
-		return nil, errors.Wrap(err, "new logfile")
+	if len(shards) == 0 {
+		return nil, errors.New("no shards")
 	}
 	srv := &Service{
-		log:     log,
-		logfile: logfile,
-		dir:     dir,
-		apiKey:  apiKey,
+		log:    log,
+		apiKey: apiKey,
+		clock:  clock,
+		format: sls.FormatLines,
+	}
+	for i, fsys := range shards {
+		s, err := newShard(log, fsys, clock)
+		if err != nil {
+			srv.Shutdown()
+			return nil, errors.Wrapf(err, "new shard %d", i)
+		}
+		srv.shards = append(srv.shards, s)
 	}
 	chain := alice.New()
 	chain = chain.Append(removeTrailingSlash)
 	chain = chain.Append(srv.isLoggedIn)
+
+	// Only posting logs is restricted by IP and client certificate, so
+	// operators can read logs from elsewhere with just the API key
+	postChain := chain.Append(srv.isAllowedIP)
+	postChain = postChain.Append(srv.hasClientCert)
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("health checked\n")
-		w.Write([]byte("OK"))
-	})
+	mux.HandleFunc("/health", srv.handleHealth)
 	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("version checked\n")
 		w.Write(version)
 	})
-	mux.Handle("/log", chain.Then(http.HandlerFunc(srv.handleLog)))
+	mux.Handle("/log", postChain.Then(http.HandlerFunc(srv.handleLog)))
+	mux.Handle("/tail", chain.Then(http.HandlerFunc(srv.handleTail)))
+	mux.Handle("/gaps", chain.Then(http.HandlerFunc(srv.handleGaps)))
 	srv.Mux = mux
 	return srv, nil
 }
 
+// WithFormat sets how entries are framed in logfiles. It defaults to
+// sls.FormatLines.
+func (srv *Service) WithFormat(format sls.Format) *Service {
+	srv.format = format
+	return srv
+}
+
+// Shutdown closes the logfile in every shard.
 func (srv *Service) Shutdown() error {
-	return srv.logfile.Close()
+	var errOut error
+	for i, s := range srv.shards {
+		if err := s.close(); err != nil && errOut == nil {
+			errOut = errors.Wrapf(err, "close shard %d", i)
+		}
+	}
+	return errOut
 }
 
 func (srv *Service) handleLog(w http.ResponseWriter, r *http.Request) {
@@ -87,22 +135,31 @@ func (srv *Service) postLog(w http.ResponseWriter, r *http.Request) {
 }
 
 func (srv *Service) execPostLog(r *http.Request) error {
-	srv.log.Printf("writing logs\n")
+	app := appFromContext(r.Context())
+	if app != "" {
+		srv.log.Printf("writing logs from %s\n", app)
+	} else {
+		srv.log.Printf("writing logs\n")
+	}
 	logs := []string{}
 	if err := json.NewDecoder(r.Body).Decode(&logs); err != nil {
 		return errors.Wrap(err, "decode body")
 	}
-	data := ""
+	data := []byte{}
 	for _, l := range logs {
-		if !strings.HasSuffix(l, "\n") {
-			l += "\n"
-		}
-		data += l
+		data = srv.format.Append(data, l)
 	}
-	srv.mu.Lock()
-	defer srv.mu.Unlock()
-	_, err := srv.logfile.Write([]byte(data))
-	return errors.Wrap(err, "write")
+	if err := srv.nextShard().write(data); err != nil {
+		return errors.Wrap(err, "write")
+	}
+	srv.tails.publish(app, logs)
+	return nil
+}
+
+// nextShard reports the shard to receive the next batch of logs.
+func (srv *Service) nextShard() *shard {
+	i := srv.next.Add(1) - 1
+	return srv.shards[i%uint64(len(srv.shards))]
 }
 
 func isClosed(err error) bool {
@@ -111,77 +168,15 @@ func isClosed(err error) bool {
 }
 
 // EnforceRetentionPolicy checks on boot and every hour log files are rotated
-// and that old files are deleted.
-func (srv *Service) EnforceRetentionPolicy(dur time.Duration) {
-	go func() {
-		if err := srv.rotateLogfile(); err != nil {
-			srv.log.Printf("failed to rotate: %s\n", err)
-		}
-		if err := srv.deleteOldFiles(dur); err != nil {
-			srv.log.Printf("failed to delete old files: %s\n", err)
-		}
-		for range time.Tick(24 * time.Hour) {
-			if err := srv.rotateLogfile(); err != nil {
-				srv.log.Printf("failed to rotate: %s\n", err)
-			}
-			if err := srv.deleteOldFiles(dur); err != nil {
-				srv.log.Printf("failed to delete old files: %s\n", err)
-			}
-		}
-	}()
-}
-
-func (srv *Service) rotateLogfile() error {
-	srv.log.Printf("rotating logfiles\n")
-	if !srv.logfile.Old() {
-		srv.log.Printf("writing to %s\n", srv.logfile.Name())
-		return nil
-	}
-	srv.log.Printf("old logfile, rotating out %s\n", srv.logfile.Name())
-	srv.mu.Lock()
-	defer srv.mu.Unlock()
-	logfile, err := sls.NewLogfile(srv.dir)
-	if err != nil {
-		return err
+// and that old files are deleted in each shard. It reports an error without
+// starting if dur isn't positive. The current logfile is kept however short
+// dur is, so retention under a day deletes only earlier days' files.
+func (srv *Service) EnforceRetentionPolicy(dur time.Duration) error {
+	if dur <= 0 {
+		return fmt.Errorf("invalid retention %s", dur)
 	}
-	srv.logfile = logfile
-	srv.log.Printf("writing to %s\n", srv.logfile.Name())
-	return nil
-}
-
-func (srv *Service) deleteOldFiles(dur time.Duration) error {
-	srv.log.Printf("deleting old logs\n")
-
-	// Get all files with *.log in logfile_dir
-	files, err := getFilesInDir(srv.dir, ".log")
-	if err != nil {
-		return errors.Wrap(err, "get files in dir")
-	}
-
-	// Sort them ascending
-	files, err = sortFilesByTimestamp(files)
-	if err != nil {
-		return errors.Wrap(err, "sort files by timestamp")
-	}
-
-	cutoff := time.Now().Add(-1 * dur)
-	for _, fi := range files {
-		// parse time in filename
-		name := strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))
-		ti, err := time.Parse("20060102", name)
-		if err != nil {
-			return errors.Wrapf(err, "invalid time %s", name)
-		}
-		if ti.After(cutoff) {
-			// We're done
-			return nil
-		}
-
-		// Delete this file and continue
-		srv.log.Printf("deleting old logfile %s\n", fi.Name())
-		if err = os.Remove(filepath.Join(srv.dir, fi.Name())); err != nil {
-			return err
-		}
+	for _, s := range srv.shards {
+		go s.enforceRetentionPolicy(dur)
 	}
 	return nil
 }
@@ -205,14 +200,15 @@ func (srv *Service) isLoggedIn(next http.Handler) http.Handler {
 	})
 }
 
-func getFilesInDir(dir, extension string) ([]os.FileInfo, error) {
+// getFilesInDir reports files with the extension in the root of fsys.
+func getFilesInDir(fsys sls.FS, extension string) ([]fs.DirEntry, error) {
 	if !strings.HasPrefix(extension, ".") {
 		extension = "." + extension
 	}
-	files := []os.FileInfo{}
-	tmp, err := ioutil.ReadDir(dir)
+	files := []fs.DirEntry{}
+	tmp, err := fsys.ReadDir(".")
 	if err != nil {
-		return nil, errors.Wrapf(err, "read dir %s", dir)
+		return nil, errors.Wrap(err, "read dir")
 	}
 	for _, fi := range tmp {
 		// Skip directories and hidden files
@@ -228,7 +224,7 @@ func getFilesInDir(dir, extension string) ([]os.FileInfo, error) {
 	return files, nil
 }
 
-func sortFilesByTimestamp(files []os.FileInfo) ([]os.FileInfo, error) {
+func sortFilesByTimestamp(files []fs.DirEntry) ([]fs.DirEntry, error) {
 	var errOut error
 	regexNum := regexp.MustCompile(`^\d+`)
 	sort.Slice(files, func(i, j int) bool {
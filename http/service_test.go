@@ -0,0 +1,253 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+// newTestService with a shard for each FS, closed when the test ends.
+func newTestService(
+	t *testing.T,
+	clock *slstest.Clock,
+	shards ...sls.FS,
+) *Service {
+	t.Helper()
+	srv, err := NewService(&testLogger{t}, shards, clock, "k", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Shutdown() })
+	return srv
+}
+
+func TestEnforceRetentionPolicyInvalid(t *testing.T) {
+	fsys := slstest.NewFS(map[string]string{"20261013.log": ""})
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	srv, err := NewService(nopLogger{}, []sls.FS{fsys}, clock, "k", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown()
+	for _, dur := range []time.Duration{0, -time.Hour} {
+		if err = srv.EnforceRetentionPolicy(dur); err == nil {
+			t.Fatalf("expected error for %s", dur)
+		}
+	}
+	want := []string{"20261013.log", "20261014.log"}
+	if got := fileNames(t, fsys); !equalStrings(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// A retention under a day puts the cutoff after the start of the
+	// current logfile, which is kept and still receives writes
+	if err = srv.EnforceRetentionPolicy(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		return equalStrings(fileNames(t, fsys), []string{"20261014.log"})
+	})
+	if err = srv.shards[0].write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	byt, err := fsys.ReadFile("20261014.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byt) != "a\n" {
+		t.Fatalf("expected write to current logfile, got %q", byt)
+	}
+}
+
+func TestPostLogShards(t *testing.T) {
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	a, b := &slstest.FS{}, &slstest.FS{}
+	srv := newTestService(t, clock, a, b)
+	for _, l := range []string{"1", "2", "3", "4", "5"} {
+		body := strings.NewReader(`["` + l + `"]`)
+		req := httptest.NewRequest("POST", "/log", body)
+		req.Header.Set("X-API-Key", "k")
+		w := httptest.NewRecorder()
+		srv.Mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+	}
+
+	// Each batch goes to the next shard in turn
+	for fsys, want := range map[*slstest.FS]string{
+		a: "1\n3\n5\n",
+		b: "2\n4\n",
+	} {
+		byt, err := fsys.ReadFile("20261014.log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(byt) != want {
+			t.Fatalf("expected %q, got %q", want, byt)
+		}
+	}
+}
+
+// countFS counts the files open in its FS, or fails to open any if fail is
+// set.
+type countFS struct {
+	*slstest.FS
+	open atomic.Int64
+	fail bool
+}
+
+func (fsys *countFS) OpenFile(
+	name string,
+	flag int,
+	perm fs.FileMode,
+) (sls.File, error) {
+	if fsys.fail {
+		return nil, errors.New("disk failed")
+	}
+	fi, err := fsys.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	fsys.open.Add(1)
+	return &countFile{File: fi, fsys: fsys}, nil
+}
+
+type countFile struct {
+	sls.File
+	fsys *countFS
+}
+
+func (f *countFile) Close() error {
+	f.fsys.open.Add(-1)
+	return f.File.Close()
+}
+
+func TestNewServiceClosesShards(t *testing.T) {
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	ok := &countFS{FS: &slstest.FS{}}
+	failed := &countFS{FS: &slstest.FS{}, fail: true}
+	_, err := NewService(&testLogger{t}, []sls.FS{ok, failed}, clock, "k", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if n := ok.open.Load(); n != 0 {
+		t.Fatalf("expected opened shard to be closed, %d files open", n)
+	}
+}
+
+func TestCheckShards(t *testing.T) {
+	errFail := errors.New("broken")
+	tcs := map[string]struct {
+		results []error
+		details []string
+		want    string
+		wantErr error
+	}{
+		"all ok": {
+			results: []error{nil, nil},
+			details: []string{"a", "b"},
+			want:    "shard 0: a; shard 1: b",
+		},
+		"one fails": {
+			results: []error{nil, errFail, errFail},
+			details: []string{"a", "", ""},
+			wantErr: errors.New("shard 1: broken; shard 2: broken"),
+		},
+		"fail beats skip": {
+			results: []error{errUnsupported, errFail},
+			details: []string{"", ""},
+			wantErr: errors.New("shard 1: broken"),
+		},
+		"some skip": {
+			results: []error{errUnsupported, nil},
+			details: []string{"", "b"},
+			want:    "shard 1: b",
+		},
+		"all skip": {
+			results: []error{errDisabled, errDisabled},
+			details: []string{"", ""},
+			wantErr: errDisabled,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			srv := &Service{}
+			index := map[*shard]int{}
+			for i := range tc.results {
+				s := &shard{}
+				index[s] = i
+				srv.shards = append(srv.shards, s)
+			}
+			detail, err := srv.checkShards(func(s *shard) (string, error) {
+				i := index[s]
+				return tc.details[i], tc.results[i]
+			})
+			switch {
+			case tc.wantErr == nil && err != nil:
+				t.Fatalf("unexpected error %s", err)
+			case tc.wantErr != nil && (err == nil ||
+				err.Error() != tc.wantErr.Error()):
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+			if detail != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, detail)
+			}
+		})
+	}
+}
+
+func BenchmarkExecPostLog(b *testing.B) {
+	// A batch like those from the client, with a stack trace to escape
+	entry := strings.Repeat("x", 100) + "\n\tat main.go:10 C:\\Users\n"
+	logs := make([]string, 10)
+	for i := range logs {
+		logs[i] = entry
+	}
+	body, err := json.Marshal(logs)
+	if err != nil {
+		b.Fatal(err)
+	}
+	formats := []sls.Format{sls.FormatLines, sls.FormatEscaped, sls.FormatLength}
+	for _, format := range formats {
+		for _, n := range []int{1, 4} {
+			name := fmt.Sprintf("%s/shards=%d", format, n)
+			b.Run(name, func(b *testing.B) {
+				shards := make([]sls.FS, n)
+				for i := range shards {
+					shards[i] = sls.DirFS(b.TempDir())
+				}
+				srv, err := NewService(nopLogger{}, shards,
+					sls.RealClock{}, "k", nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer srv.Shutdown()
+				srv.WithFormat(format)
+				b.SetBytes(int64(len(logs) * len(entry)))
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						req := httptest.NewRequest("POST", "/log",
+							bytes.NewReader(body))
+						if err := srv.execPostLog(req); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
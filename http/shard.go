@@ -0,0 +1,170 @@
+package http
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/pkg/errors"
+)
+
+// shard is a single log directory, typically on its own disk. Each shard has
+// its own lock, so writes to different shards proceed in parallel.
+type shard struct {
+	fsys    sls.FS
+	clock   sls.Clock
+	log     sls.Logger
+	logfile *sls.Logfile
+
+	// name of the current logfile, stored separately so it can be
+	// reported without waiting on mu.
+	name atomic.Value
+
+	// pending is the number of writes waiting on or holding mu.
+	pending atomic.Int64
+
+	// lockedAt is the time in Unix nanoseconds when mu was last acquired,
+	// or 0 if it's unlocked. It's used to detect stuck writes without
+	// waiting on mu. Only access mu through lock and unlock.
+	lockedAt atomic.Int64
+
+	// mu protects changes to the logfile when rotating or writing to it.
+	mu sync.Mutex
+}
+
+func newShard(log sls.Logger, fsys sls.FS, clock sls.Clock) (*shard, error) {
+	logfile, err := sls.NewLogfile(fsys, clock)
+	if err != nil {
+		return nil, errors.Wrap(err, "new logfile")
+	}
+	s := &shard{
+		fsys:    fsys,
+		clock:   clock,
+		log:     log,
+		logfile: logfile,
+	}
+	s.name.Store(logfile.Name())
+	return s, nil
+}
+
+func (s *shard) lock() {
+	s.mu.Lock()
+	s.lockedAt.Store(s.clock.Now().UnixNano())
+}
+
+func (s *shard) unlock() {
+	s.lockedAt.Store(0)
+	s.mu.Unlock()
+}
+
+// lockHeldFor reports how long the current holder of mu has held it, or 0 if
+// mu is unlocked.
+func (s *shard) lockHeldFor() time.Duration {
+	at := s.lockedAt.Load()
+	if at == 0 {
+		return 0
+	}
+	return s.clock.Now().Sub(time.Unix(0, at))
+}
+
+// write the data to the shard's current logfile.
+func (s *shard) write(byt []byte) error {
+	s.pending.Add(1)
+	defer s.pending.Add(-1)
+	s.lock()
+	defer s.unlock()
+	_, err := s.logfile.Write(byt)
+	return err
+}
+
+func (s *shard) close() error {
+	s.lock()
+	defer s.unlock()
+	return s.logfile.Close()
+}
+
+func (s *shard) enforceRetentionPolicy(dur time.Duration) {
+	if err := s.rotateLogfile(); err != nil {
+		s.log.Printf("failed to rotate: %s\n", err)
+	}
+	if err := s.deleteOldFiles(dur); err != nil {
+		s.log.Printf("failed to delete old files: %s\n", err)
+	}
+	for range s.clock.Tick(time.Hour) {
+		if err := s.rotateLogfile(); err != nil {
+			s.log.Printf("failed to rotate: %s\n", err)
+		}
+		if err := s.deleteOldFiles(dur); err != nil {
+			s.log.Printf("failed to delete old files: %s\n", err)
+		}
+	}
+}
+
+func (s *shard) rotateLogfile() error {
+	s.log.Printf("rotating logfiles\n")
+	s.lock()
+	defer s.unlock()
+	if !s.logfile.Old() {
+		s.log.Printf("writing to %s\n", s.logfile.Name())
+		return nil
+	}
+	s.log.Printf("old logfile, rotating out %s\n", s.logfile.Name())
+	logfile, err := sls.NewLogfile(s.fsys, s.clock)
+	if err != nil {
+		return err
+	}
+	if err = s.logfile.Close(); err != nil {
+		s.log.Printf("failed to close %s: %s\n", s.logfile.Name(), err)
+	}
+	s.logfile = logfile
+	s.name.Store(logfile.Name())
+	s.log.Printf("writing to %s\n", s.logfile.Name())
+	return nil
+}
+
+func (s *shard) deleteOldFiles(dur time.Duration) error {
+	s.log.Printf("deleting old logs\n")
+
+	// Get all files with *.log in logfile_dir
+	files, err := getFilesInDir(s.fsys, ".log")
+	if err != nil {
+		return errors.Wrap(err, "get files in dir")
+	}
+
+	// Sort them ascending
+	files, err = sortFilesByTimestamp(files)
+	if err != nil {
+		return errors.Wrap(err, "sort files by timestamp")
+	}
+
+	// Never delete the logfile being written, however short the
+	// retention, or every write after it would be lost
+	current, _ := s.name.Load().(string)
+	cutoff := s.clock.Now().Add(-1 * dur)
+	for _, fi := range files {
+		if fi.Name() == current {
+			continue
+		}
+
+		// parse time in filename
+		name := strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))
+		ti, err := time.Parse("20060102", name)
+		if err != nil {
+			return errors.Wrapf(err, "invalid time %s", name)
+		}
+		if ti.After(cutoff) {
+			// We're done
+			return nil
+		}
+
+		// Delete this file and continue
+		s.log.Printf("deleting old logfile %s\n", fi.Name())
+		if err = s.fsys.Remove(fi.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package http
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+// testLogger satisfies sls.Logger by logging to the test.
+type testLogger struct{ t *testing.T }
+
+func (l *testLogger) Printf(s string, vs ...interface{}) {
+	l.t.Helper()
+	l.t.Logf(s, vs...)
+}
+
+// nopLogger discards logs from goroutines which may outlive a test.
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+func newTestShard(
+	t *testing.T,
+	fsys sls.FS,
+	clock *slstest.Clock,
+) *shard {
+	t.Helper()
+	s, err := newShard(&testLogger{t}, fsys, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.close() })
+	return s
+}
+
+func fileNames(t *testing.T, fsys *slstest.FS) []string {
+	t.Helper()
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestShardRotateLogfile(t *testing.T) {
+	fsys := &slstest.FS{}
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC))
+	s := newTestShard(t, fsys, clock)
+	if err := s.write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotating before midnight UTC keeps the current file
+	clock.Advance(59 * time.Minute)
+	if err := s.rotateLogfile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Minute)
+	if err := s.rotateLogfile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write([]byte("c\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"20261014.log", "20261015.log"}
+	if got := fileNames(t, fsys); !equalStrings(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for name, want := range map[string]string{
+		"20261014.log": "a\nb\n",
+		"20261015.log": "c\n",
+	} {
+		byt, err := fsys.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(byt) != want {
+			t.Fatalf("%s: expected %q, got %q", name, want, byt)
+		}
+	}
+	if got := s.name.Load().(string); got != "20261015.log" {
+		t.Fatalf("expected current file 20261015.log, got %s", got)
+	}
+}
+
+func TestShardRestartMidDay(t *testing.T) {
+	fsys := slstest.NewFS(map[string]string{"20261014.log": "a\n"})
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	s := newTestShard(t, fsys, clock)
+	if err := s.rotateLogfile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	byt, err := fsys.ReadFile("20261014.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byt) != "a\nb\n" {
+		t.Fatalf("expected appended logs, got %q", byt)
+	}
+}
+
+func TestShardDeleteOldFiles(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	tcs := map[string]struct {
+		files map[string]string
+		dur   time.Duration
+		want  []string
+	}{
+		"empty dir": {
+			files: map[string]string{},
+			dur:   24 * time.Hour,
+			want:  []string{},
+		},
+		"past cutoff": {
+			// The cutoff is 2026-10-11 12:00, so the file for
+			// 2026-10-11 started before it and is deleted
+			files: map[string]string{
+				"20261009.log": "",
+				"20261010.log": "",
+				"20261011.log": "",
+				"20261012.log": "",
+				"20261013.log": "",
+				"20261014.log": "",
+			},
+			dur: 3 * 24 * time.Hour,
+			want: []string{
+				"20261012.log",
+				"20261013.log",
+				"20261014.log",
+			},
+		},
+		"skips other files": {
+			files: map[string]string{
+				"20261001.log":  "",
+				"20261001.txt":  "",
+				".20261001.log": "",
+				"20261014.log":  "",
+			},
+			dur: 24 * time.Hour,
+			want: []string{
+				".20261001.log",
+				"20261001.txt",
+				"20261014.log",
+			},
+		},
+		"all within retention": {
+			files: map[string]string{
+				"20261013.log": "",
+				"20261014.log": "",
+			},
+			dur:  30 * 24 * time.Hour,
+			want: []string{"20261013.log", "20261014.log"},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			fsys := slstest.NewFS(tc.files)
+			s := &shard{
+				fsys:  fsys,
+				clock: slstest.NewClock(now),
+				log:   &testLogger{t},
+			}
+			if err := s.deleteOldFiles(tc.dur); err != nil {
+				t.Fatal(err)
+			}
+			if got := fileNames(t, fsys); !equalStrings(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestShardEnforceRetentionPolicy(t *testing.T) {
+	fsys := slstest.NewFS(map[string]string{"20261001.log": ""})
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 23, 30, 0, 0, time.UTC))
+	s := newTestShard(t, fsys, clock)
+	s.log = nopLogger{}
+	go s.enforceRetentionPolicy(7 * 24 * time.Hour)
+
+	// The first pass on boot deletes the old file. Advancing past the
+	// hourly tick then rotates into the next day's file.
+	waitFor(t, func() bool {
+		return equalStrings(fileNames(t, fsys), []string{"20261014.log"})
+	})
+	clock.Advance(time.Hour)
+	waitFor(t, func() bool {
+		return s.name.Load().(string) == "20261015.log"
+	})
+}
+
+// waitFor the condition to be true, failing the test after a second.
+func waitFor(t *testing.T, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
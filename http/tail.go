@@ -0,0 +1,197 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/egtann/sls"
+)
+
+// tailBuffer is the number of lines buffered for each tail subscriber. Once
+// full, new lines for that subscriber are dropped rather than blocking
+// writes.
+const tailBuffer = 1024
+
+// tails fans out newly written logs to subscribers.
+type tails struct {
+	// mu protects subs and stopped. subs is replaced rather than modified
+	// in place, so publish can match and send outside the lock using a
+	// snapshot.
+	mu   sync.Mutex
+	subs []*subscriber
+
+	// stopped is closed by stop to end every stream.
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+type subscriber struct {
+	filter *sls.Filter
+	ch     chan string
+
+	// dropped is the number of lines discarded because the subscriber
+	// fell behind.
+	dropped atomic.Uint64
+}
+
+func (t *tails) subscribe(filter *sls.Filter) *subscriber {
+	sub := &subscriber{
+		filter: filter,
+		ch:     make(chan string, tailBuffer),
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	subs := make([]*subscriber, len(t.subs), len(t.subs)+1)
+	copy(subs, t.subs)
+	t.subs = append(subs, sub)
+	return sub
+}
+
+// done reports a channel which is closed once tails are stopped.
+func (t *tails) done() chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped == nil {
+		t.stopped = make(chan struct{})
+	}
+	return t.stopped
+}
+
+func (t *tails) stop() {
+	done := t.done()
+	t.stopOnce.Do(func() { close(done) })
+}
+
+func (t *tails) unsubscribe(sub *subscriber) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	subs := make([]*subscriber, 0, len(t.subs))
+	for _, s := range t.subs {
+		if s != sub {
+			subs = append(subs, s)
+		}
+	}
+	t.subs = subs
+}
+
+// snapshot reports the current subscribers. The slice must not be modified.
+func (t *tails) snapshot() []*subscriber {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.subs
+}
+
+// publish sends each log matching a subscriber's filter to that subscriber.
+// Filters are evaluated here, before fan-out, so subscribers only receive the
+// lines they asked for. Matching happens outside the lock, so concurrent
+// posts to different shards don't serialize on it, and publish never blocks
+// on slow subscribers.
+func (t *tails) publish(app string, logs []string) {
+	subs := t.snapshot()
+	if len(subs) == 0 {
+		return
+	}
+	var defaults map[string]string
+	if app != "" {
+		defaults = map[string]string{"app": app}
+	}
+	for _, sub := range subs {
+		for _, l := range logs {
+			if !sub.filter.Match(l, defaults) {
+				continue
+			}
+			select {
+			case sub.ch <- l:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// state of each subscriber for debugging.
+func (t *tails) state() []tailState {
+	states := []tailState{}
+	for _, sub := range t.snapshot() {
+		states = append(states, tailState{
+			Filter:  sub.filter.String(),
+			Depth:   len(sub.ch),
+			Dropped: sub.dropped.Load(),
+		})
+	}
+	return states
+}
+
+// StopTails ends every tail stream, which otherwise stay open until the client
+// disconnects. Pass it to http.Server.RegisterOnShutdown so that tails don't
+// prevent a graceful shutdown.
+func (srv *Service) StopTails() { srv.tails.stop() }
+
+// handleTail streams logs as they're written until the client disconnects.
+// The optional filter query parameter restricts the stream to matching lines
+// using the syntax described on sls.Filter. The optional format query
+// parameter frames each entry as described on sls.Format, so multiline
+// entries can be read back as single records. It defaults to the storage
+// format.
+func (srv *Service) handleTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+	filter, err := sls.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := srv.format
+	if f := r.URL.Query().Get("format"); f != "" {
+		format, err = sls.ParseFormat(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	srv.log.Printf("tailing logs with filter %q\n", filter)
+	sub := srv.tails.subscribe(filter)
+	defer srv.tails.unsubscribe(sub)
+	stopped := srv.tails.done()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stopped:
+			return
+		case l := <-sub.ch:
+			if err = writeTailEntry(w, format, l); err != nil {
+				if !isClosed(err) {
+					srv.log.Printf("failed to tail: %s\n", err)
+				}
+				return
+			}
+
+			// Drain anything else buffered before flushing, so
+			// bursts are sent together
+			for n := len(sub.ch); n > 0; n-- {
+				if err = writeTailEntry(w, format, <-sub.ch); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeTailEntry(w http.ResponseWriter, format sls.Format, l string) error {
+	_, err := w.Write(format.Append(nil, l))
+	return err
+}
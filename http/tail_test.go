@@ -0,0 +1,78 @@
+package http
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/egtann/sls"
+)
+
+func TestTailsPublish(t *testing.T) {
+	var ts tails
+	all, err := sls.ParseFilter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	warn, err := sls.ParseFilter("app=api level>=warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subAll := ts.subscribe(all)
+	subWarn := ts.subscribe(warn)
+	ts.publish("api", []string{"level=info msg=a", "level=error msg=b"})
+	ts.publish("web", []string{"level=error msg=c"})
+	if len(subAll.ch) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(subAll.ch))
+	}
+	if len(subWarn.ch) != 1 || <-subWarn.ch != "level=error msg=b" {
+		t.Fatal("expected only the api error")
+	}
+
+	// Unsubscribed tails receive nothing
+	ts.unsubscribe(subWarn)
+	ts.publish("api", []string{"level=error msg=d"})
+	if len(subWarn.ch) != 0 {
+		t.Fatal("unsubscribed tail received logs")
+	}
+	if got := len(ts.state()); got != 1 {
+		t.Fatalf("expected 1 tail, got %d", got)
+	}
+}
+
+func TestTailsPublishDrops(t *testing.T) {
+	var ts tails
+	f, err := sls.ParseFilter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := ts.subscribe(f)
+	logs := make([]string, tailBuffer+10)
+	for i := range logs {
+		logs[i] = "x"
+	}
+	ts.publish("", logs)
+	if got := sub.dropped.Load(); got != 10 {
+		t.Fatalf("expected 10 dropped, got %d", got)
+	}
+}
+
+func TestTailsConcurrent(t *testing.T) {
+	var ts tails
+	f, err := sls.ParseFilter("msg~x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ts.unsubscribe(ts.subscribe(f))
+		}()
+		go func() {
+			defer wg.Done()
+			ts.publish("", []string{"x"})
+		}()
+	}
+	wg.Wait()
+}
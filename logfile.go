@@ -2,8 +2,6 @@ package sls
 
 import (
 	"os"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,7 +12,8 @@ import (
 // writes are atomic up to a certain size, but SLS does not limit the size of
 // a request.
 type Logfile struct {
-	fi      *os.File
+	fi      File
+	clock   Clock
 	created time.Time
 }
 
@@ -29,29 +28,33 @@ func (l *Logfile) Close() error { return l.fi.Close() }
 // Name of the current logfile.
 func (l *Logfile) Name() string { return l.fi.Name() }
 
-// Old reports whether the logfile is older than 24 hours and needs to be
+// Old reports whether the logfile is from a previous UTC day and needs to be
 // rotated.
 func (l *Logfile) Old() bool {
-	return l.created.Before(time.Now().Add(-24 * time.Hour))
+	return !l.created.Equal(truncateDay(l.clock.Now()))
 }
 
-// NewLogfile creates or gets an existing logfile at a given directory.
-func NewLogfile(dir string) (*Logfile, error) {
-	if !strings.HasSuffix(dir, string(filepath.Separator)) {
-		return nil, errors.New("logfile directory must end with filepath separator")
-	}
-	// Truncate sub-day time information to consistently rotate files after
-	// 24 hours, even if the file exists in
-	now := time.Now()
-	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-	filename := dir + now.Format("20060102") + ".log"
-	fi, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+// NewLogfile creates or gets an existing logfile for the current UTC day in
+// the root of fsys.
+func NewLogfile(fsys FS, clock Clock) (*Logfile, error) {
+	// Truncate sub-day time information to consistently rotate files at
+	// midnight UTC, even if the file already exists from an earlier boot.
+	// UTC avoids DST shifting the boundary.
+	now := truncateDay(clock.Now())
+	filename := now.Format("20060102") + ".log"
+	fi, err := fsys.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return nil, errors.Wrap(err, "open")
 	}
 	logfile := &Logfile{
 		fi:      fi,
+		clock:   clock,
 		created: now,
 	}
 	return logfile, nil
 }
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
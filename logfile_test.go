@@ -0,0 +1,173 @@
+package sls_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/egtann/sls"
+	"github.com/egtann/sls/slstest"
+)
+
+func TestNewLogfile(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := map[string]struct {
+		now  time.Time
+		want string
+	}{
+		"midnight UTC": {
+			now:  time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC),
+			want: "20261014.log",
+		},
+		"before midnight UTC": {
+			now:  time.Date(2026, 10, 14, 23, 59, 59, 0, time.UTC),
+			want: "20261014.log",
+		},
+		"local day behind UTC": {
+			// 20:00 EST is 01:00 UTC the next day
+			now:  time.Date(2026, 11, 1, 20, 0, 0, 0, nyc),
+			want: "20261102.log",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			fsys := &slstest.FS{}
+			logfile, err := sls.NewLogfile(fsys, slstest.NewClock(tc.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logfile.Close()
+			if logfile.Name() != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, logfile.Name())
+			}
+		})
+	}
+}
+
+func TestLogfileEmptyDir(t *testing.T) {
+	fsys := &slstest.FS{}
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC))
+	logfile, err := sls.NewLogfile(fsys, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logfile.Close()
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "20261014.log" {
+		t.Fatalf("expected only 20261014.log, got %v", entries)
+	}
+}
+
+func TestLogfileOld(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := map[string]struct {
+		start   time.Time
+		advance time.Duration
+		want    bool
+	}{
+		"same day": {
+			start:   time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC),
+			advance: 23*time.Hour + 59*time.Minute,
+			want:    false,
+		},
+		"UTC midnight": {
+			start:   time.Date(2026, 10, 14, 23, 59, 59, 0, time.UTC),
+			advance: time.Second,
+			want:    true,
+		},
+		"restart mid-day": {
+			// Files are keyed on the day, not the time they were
+			// opened, so a file opened mid-day rotates at midnight
+			// rather than 24 hours later
+			start:   time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC),
+			advance: 6 * time.Hour,
+			want:    true,
+		},
+		"DST starts in local zone": {
+			// 01:30 EST is 06:30 UTC. The clocks jump forward an
+			// hour locally, but the UTC day doesn't change.
+			start:   time.Date(2026, 3, 8, 1, 30, 0, 0, nyc),
+			advance: time.Hour,
+			want:    false,
+		},
+		"DST ends in local zone": {
+			// 01:30 EDT repeats as 01:30 EST an hour later, which
+			// is still the same UTC day
+			start:   time.Date(2026, 11, 1, 1, 30, 0, 0, nyc),
+			advance: time.Hour,
+			want:    false,
+		},
+		"local midnight is not UTC midnight": {
+			// 23:00 EDT is 03:00 UTC, so crossing local midnight
+			// doesn't rotate
+			start:   time.Date(2026, 10, 14, 23, 0, 0, 0, nyc),
+			advance: 2 * time.Hour,
+			want:    false,
+		},
+		"UTC midnight in local zone": {
+			// 19:30 EDT is 23:30 UTC
+			start:   time.Date(2026, 10, 14, 19, 30, 0, 0, nyc),
+			advance: time.Hour,
+			want:    true,
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			clock := slstest.NewClock(tc.start)
+			logfile, err := sls.NewLogfile(&slstest.FS{}, clock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer logfile.Close()
+			if logfile.Old() {
+				t.Fatal("new logfile is old")
+			}
+			clock.Advance(tc.advance)
+			if got := logfile.Old(); got != tc.want {
+				t.Fatalf("expected old %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLogfileReopen(t *testing.T) {
+	fsys := &slstest.FS{}
+	clock := slstest.NewClock(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	logfile, err := sls.NewLogfile(fsys, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = logfile.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = logfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restarting later the same day appends to the existing file
+	clock.Advance(5 * time.Hour)
+	logfile, err = sls.NewLogfile(fsys, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logfile.Close()
+	if _, err = logfile.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	byt, err := fsys.ReadFile("20261014.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byt) != "a\nb\n" {
+		t.Fatalf("expected appended logs, got %q", byt)
+	}
+}
@@ -0,0 +1,69 @@
+package slstest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is an sls.Clock which only moves when advanced, so tests can cross
+// day boundaries deterministically.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+type ticker struct {
+	ch   chan time.Time
+	dur  time.Duration
+	next time.Time
+}
+
+// NewClock reports a Clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now reports the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Tick reports a channel which receives the time at every interval as the
+// clock is advanced. Like time.Tick, ticks are dropped if the receiver falls
+// behind.
+func (c *Clock) Tick(dur time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &ticker{
+		ch:   make(chan time.Time, 1),
+		dur:  dur,
+		next: c.now.Add(dur),
+	}
+	c.tickers = append(c.tickers, t)
+	return t.ch
+}
+
+// Advance the clock by dur, firing any ticks which elapse.
+func (c *Clock) Advance(dur time.Duration) {
+	c.Set(c.Now().Add(dur))
+}
+
+// Set the clock to now, firing any ticks which elapse. Setting the clock
+// backwards fires nothing.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	for _, t := range c.tickers {
+		for !t.next.After(now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.dur)
+		}
+	}
+}
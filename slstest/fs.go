@@ -0,0 +1,136 @@
+// Package slstest provides an in-memory filesystem and a manually advanced
+// clock for testing code built on sls.
+package slstest
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"github.com/egtann/sls"
+)
+
+// FS is an in-memory sls.FS. Files are flat and live in the root. The zero
+// value is an empty FS ready to use.
+type FS struct {
+	mu    sync.Mutex
+	files map[string]*fstest.MapFile
+}
+
+// NewFS reports an FS containing files with the given names and contents.
+func NewFS(files map[string]string) *FS {
+	fsys := &FS{}
+	for name, data := range files {
+		fsys.set(name, []byte(data))
+	}
+	return fsys
+}
+
+func (fsys *FS) set(name string, data []byte) *fstest.MapFile {
+	if fsys.files == nil {
+		fsys.files = map[string]*fstest.MapFile{}
+	}
+	f := &fstest.MapFile{Data: data, Mode: 0644, ModTime: time.Now()}
+	fsys.files[name] = f
+	return f
+}
+
+// snapshot copies the current files, so readers don't race with writes.
+func (fsys *FS) snapshot() fstest.MapFS {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	m := fstest.MapFS{}
+	for name, f := range fsys.files {
+		cp := *f
+		cp.Data = append([]byte(nil), f.Data...)
+		m[name] = &cp
+	}
+	return m
+}
+
+// Open the named file for reading. Reads see the file's contents at the time
+// it was opened.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	return fsys.snapshot().Open(name)
+}
+
+// ReadDir reads the named directory, sorted by filename.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fsys.snapshot().ReadDir(name)
+}
+
+// ReadFile reports the contents of the named file.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	return fsys.snapshot().ReadFile(name)
+}
+
+// OpenFile opens the named file for writing. Every write appends, as with
+// os.O_APPEND. O_CREATE, O_EXCL, and O_TRUNC behave as with os.OpenFile.
+func (fsys *FS) OpenFile(
+	name string,
+	flag int,
+	perm fs.FileMode,
+) (sls.File, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	f, ok := fsys.files[name]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case !ok:
+		f = fsys.set(name, nil)
+	case flag&os.O_TRUNC != 0:
+		f.Data = nil
+	}
+	return &file{fsys: fsys, name: name, f: f}, nil
+}
+
+// Remove the named file. Like on disk, an open file which is removed can
+// still be written, but the writes aren't visible in the FS.
+func (fsys *FS) Remove(name string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if _, ok := fsys.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(fsys.files, name)
+	return nil
+}
+
+// file is a writable handle to a file in an FS.
+type file struct {
+	fsys   *FS
+	name   string
+	f      *fstest.MapFile
+	closed bool
+}
+
+func (f *file) Write(byt []byte) (int, error) {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.f.Data = append(f.f.Data, byt...)
+	f.f.ModTime = time.Now()
+	return len(byt), nil
+}
+
+func (f *file) Close() error {
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *file) Name() string { return f.name }
@@ -0,0 +1,71 @@
+package slstest
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	fsys := NewFS(map[string]string{"a.log": "a\n", "b.log": ""})
+	if err := fstest.TestFS(fsys, "a.log", "b.log"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSOpenFile(t *testing.T) {
+	fsys := &FS{}
+	if _, err := fsys.OpenFile("a.log", os.O_WRONLY, 0644); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+	fi, err := fsys.OpenFile("a.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fi.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = fsys.Remove("a.log"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes to removed files succeed, as on disk, but aren't visible
+	if _, err = fi.Write([]byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fsys.ReadFile("a.log"); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+	if err = fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fi.Write([]byte("c\n")); err == nil {
+		t.Fatal("expected error writing closed file")
+	}
+}
+
+func TestClockTick(t *testing.T) {
+	start := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+	tick := clock.Tick(time.Hour)
+	clock.Advance(59 * time.Minute)
+	select {
+	case <-tick:
+		t.Fatal("ticked early")
+	default:
+	}
+	clock.Advance(time.Minute)
+	if got := <-tick; !got.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected tick at %s, got %s", start.Add(time.Hour), got)
+	}
+
+	// Ticks are dropped when the receiver falls behind
+	clock.Advance(3 * time.Hour)
+	<-tick
+	select {
+	case <-tick:
+		t.Fatal("expected dropped ticks")
+	default:
+	}
+}